@@ -0,0 +1,207 @@
+package vatspy
+
+import (
+	"github.com/viert/go-vatspy/dynamic"
+)
+
+// changedFields returns paths of the fields which differ between
+// the previous and the current version of an object
+func changedFields(prev interface{}, cur interface{}) []string {
+	switch c := cur.(type) {
+	case Airport:
+		if p, ok := prev.(Airport); ok {
+			return airportChanges(&p, &c)
+		}
+	case Radar:
+		if p, ok := prev.(Radar); ok {
+			return radarChanges(&p, &c)
+		}
+	case Country:
+		if p, ok := prev.(Country); ok {
+			return countryChanges(&p, &c)
+		}
+	case Pilot:
+		if p, ok := prev.(Pilot); ok {
+			return pilotChanges(&p, &c)
+		}
+	}
+	return nil
+}
+
+func countryChanges(a *Country, b *Country) []string {
+	changes := make([]string, 0)
+	if a.Name != b.Name {
+		changes = append(changes, "Name")
+	}
+	if a.ControlCustomName != b.ControlCustomName {
+		changes = append(changes, "ControlCustomName")
+	}
+	return changes
+}
+
+func airportChanges(a *Airport, b *Airport) []string {
+	changes := make([]string, 0)
+	if a.Name != b.Name {
+		changes = append(changes, "Name")
+	}
+	if a.IATA != b.IATA {
+		changes = append(changes, "IATA")
+	}
+	if a.FIRID != b.FIRID {
+		changes = append(changes, "FIRID")
+	}
+	if a.IsPseudo != b.IsPseudo {
+		changes = append(changes, "IsPseudo")
+	}
+	if a.Position != b.Position {
+		changes = append(changes, "Position")
+	}
+
+	changes = append(changes, airportControllerChanges("Controllers.ATIS", a.Controllers.ATIS, b.Controllers.ATIS)...)
+	changes = append(changes, airportControllerChanges("Controllers.Delivery", a.Controllers.Delivery, b.Controllers.Delivery)...)
+	changes = append(changes, airportControllerChanges("Controllers.Ground", a.Controllers.Ground, b.Controllers.Ground)...)
+	changes = append(changes, airportControllerChanges("Controllers.Tower", a.Controllers.Tower, b.Controllers.Tower)...)
+	changes = append(changes, airportControllerChanges("Controllers.Approach", a.Controllers.Approach, b.Controllers.Approach)...)
+	return changes
+}
+
+// airportControllerChanges reports the facility path itself if the controller
+// has gone online, offline or has been replaced by another one, and the facility
+// path followed by field paths if the same controller has changed
+func airportControllerChanges(path string, a *AirportController, b *AirportController) []string {
+	if a.equals(b) {
+		return nil
+	}
+	changes := []string{path}
+	if a != nil && b != nil && a.Callsign == b.Callsign {
+		changes = append(changes, controllerChanges(path+".", &a.Controller, &b.Controller)...)
+	}
+	return changes
+}
+
+func controllerChanges(prefix string, a *dynamic.Controller, b *dynamic.Controller) []string {
+	changes := make([]string, 0)
+	if a.Cid != b.Cid {
+		changes = append(changes, prefix+"Cid")
+	}
+	if a.Name != b.Name {
+		changes = append(changes, prefix+"Name")
+	}
+	if a.Callsign != b.Callsign {
+		changes = append(changes, prefix+"Callsign")
+	}
+	if a.Frequency != b.Frequency {
+		changes = append(changes, prefix+"Frequency")
+	}
+	if a.Facility != b.Facility {
+		changes = append(changes, prefix+"Facility")
+	}
+	if a.Rating != b.Rating {
+		changes = append(changes, prefix+"Rating")
+	}
+	if a.Server != b.Server {
+		changes = append(changes, prefix+"Server")
+	}
+	if a.VisualRange != b.VisualRange {
+		changes = append(changes, prefix+"VisualRange")
+	}
+	if a.AtisCode != b.AtisCode {
+		changes = append(changes, prefix+"AtisCode")
+	}
+	if !stringsEqual(a.TextAtis, b.TextAtis) {
+		changes = append(changes, prefix+"TextAtis")
+	}
+	if a.LogonTime != b.LogonTime {
+		changes = append(changes, prefix+"LogonTime")
+	}
+	return changes
+}
+
+func radarChanges(a *Radar, b *Radar) []string {
+	changes := controllerChanges("", &a.Controller, &b.Controller)
+	if a.HumanReadableName != b.HumanReadableName {
+		changes = append(changes, "HumanReadableName")
+	}
+	if !firsEqual(a.FIRs, b.FIRs) {
+		changes = append(changes, "FIRs")
+	}
+	return changes
+}
+
+func pilotChanges(a *Pilot, b *Pilot) []string {
+	changes := make([]string, 0)
+	if a.Cid != b.Cid {
+		changes = append(changes, "Cid")
+	}
+	if a.Name != b.Name {
+		changes = append(changes, "Name")
+	}
+	if a.Server != b.Server {
+		changes = append(changes, "Server")
+	}
+	if a.PilotRating != b.PilotRating {
+		changes = append(changes, "PilotRating")
+	}
+	if a.Latitude != b.Latitude {
+		changes = append(changes, "Latitude")
+	}
+	if a.Longitude != b.Longitude {
+		changes = append(changes, "Longitude")
+	}
+	if a.Altitude != b.Altitude {
+		changes = append(changes, "Altitude")
+	}
+	if a.Groundspeed != b.Groundspeed {
+		changes = append(changes, "Groundspeed")
+	}
+	if a.Transponder != b.Transponder {
+		changes = append(changes, "Transponder")
+	}
+	if a.Heading != b.Heading {
+		changes = append(changes, "Heading")
+	}
+	if a.QnhIHg != b.QnhIHg {
+		changes = append(changes, "QnhIHg")
+	}
+	if a.QnhMb != b.QnhMb {
+		changes = append(changes, "QnhMb")
+	}
+	if !flightPlansEqual(a.FlightPlan, b.FlightPlan) {
+		changes = append(changes, "FlightPlan")
+	}
+	if a.LogonTime != b.LogonTime {
+		changes = append(changes, "LogonTime")
+	}
+	return changes
+}
+
+func stringsEqual(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func firsEqual(a []*FIR, b []*FIR) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].equals(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func flightPlansEqual(a *dynamic.FlightPlan, b *dynamic.FlightPlan) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
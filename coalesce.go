@@ -0,0 +1,128 @@
+package vatspy
+
+import (
+	"container/list"
+	"sync"
+)
+
+// coalesceQueue is a queue of pending updates keyed by object identity.
+// Only the latest update of each object is kept
+type coalesceQueue struct {
+	lock   sync.Mutex
+	order  *list.List
+	byKey  map[string]*list.Element
+	notify chan struct{}
+	quit   chan struct{}
+}
+
+func newCoalesceQueue() *coalesceQueue {
+	return &coalesceQueue{
+		order:  list.New(),
+		byKey:  make(map[string]*list.Element),
+		notify: make(chan struct{}, 1),
+		quit:   make(chan struct{}),
+	}
+}
+
+// push merges the update into the queue
+func (q *coalesceQueue) push(update Update) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if update.Type == StateReset {
+		// everything pending is obsolete
+		q.order.Init()
+		q.byKey = make(map[string]*list.Element)
+		q.order.PushBack(update)
+		q.signal()
+		return
+	}
+
+	key := update.Key()
+	elem, found := q.byKey[key]
+	if !found {
+		q.byKey[key] = q.order.PushBack(update)
+		q.signal()
+		return
+	}
+
+	pending := elem.Value.(Update)
+	switch pending.Type {
+	case ObjectAdd:
+		if update.Type == ObjectRemove {
+			// the consumer has never seen the object, nothing to deliver
+			q.order.Remove(elem)
+			delete(q.byKey, key)
+			return
+		}
+		update.Type = ObjectAdd
+		update.Previous = nil
+		update.Changes = nil
+	default:
+		// the consumer still has the version the pending update was based on
+		update.Previous = pending.Previous
+		if update.Type == ObjectAdd {
+			update.Type = ObjectModify
+		}
+		if update.Type == ObjectModify {
+			update.Changes = changedFields(update.Previous, update.Object)
+		}
+	}
+	elem.Value = update
+}
+
+// pop removes the first pending update from the queue
+func (q *coalesceQueue) pop() (Update, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	elem := q.order.Front()
+	if elem == nil {
+		return Update{}, false
+	}
+	update := q.order.Remove(elem).(Update)
+	if key := update.Key(); key != "" {
+		delete(q.byKey, key)
+	}
+	return update, true
+}
+
+// len returns the number of pending updates
+func (q *coalesceQueue) len() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.order.Len()
+}
+
+func (q *coalesceQueue) signal() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// pump moves pending updates to the updates channel as the consumer reads them.
+// The updates channel is closed when the subscription is closed
+func (s *Subscription) pump() {
+	defer close(s.updates)
+	q := s.coalesce
+	for {
+		select {
+		case <-q.quit:
+			return
+		case <-q.notify:
+		}
+
+		for {
+			update, ok := q.pop()
+			if !ok {
+				break
+			}
+			select {
+			case s.updates <- update:
+			case <-q.quit:
+				return
+			}
+		}
+	}
+}
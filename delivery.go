@@ -0,0 +1,291 @@
+package vatspy
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// DeliveryPolicy defines what a subscription does when its buffer is full
+type DeliveryPolicy int
+
+// DeliveryPolicy enum definition
+const (
+	// DropNewest drops the update being sent. The update is not applied
+	// to the subscription state, the current version of the object
+	// is sent with the next fetch cycle instead
+	DropNewest DeliveryPolicy = iota
+	// DropOldest drops the oldest buffered update to make room for the new one.
+	// The current version of the evicted update's object is sent
+	// with the next fetch cycle
+	DropOldest
+	// Block waits for the consumer until BlockTimeout expires,
+	// the update is dropped as with DropNewest on timeout
+	Block
+	// Coalesce keeps pending updates in a queue keyed by object identity
+	// so that only the latest state of each object is delivered.
+	// Nothing is ever dropped and the queue is bounded by the number
+	// of objects. Use a zero ChanSize to keep all the pending updates coalescable
+	Coalesce
+)
+
+var deliveryPolicyNames = map[DeliveryPolicy]string{
+	DropNewest: "drop-newest",
+	DropOldest: "drop-oldest",
+	Block:      "block",
+	Coalesce:   "coalesce",
+}
+
+func (dp DeliveryPolicy) String() string {
+	return deliveryPolicyNames[dp]
+}
+
+// SubscriptionOptions is a subscription configuration
+type SubscriptionOptions struct {
+	// ChanSize is the updates channel buffer size
+	ChanSize int
+	// ControlledOnly skips airports with no controllers online
+	ControlledOnly bool
+	// Filters are applied to every object before sending,
+	// an object is sent only if all the filters return true
+	Filters []UpdateFilter
+	// Delivery defines what happens when the buffer is full
+	Delivery DeliveryPolicy
+	// BlockTimeout limits waiting for the consumer with the Block policy,
+	// zero means waiting until the update is read, the subscription
+	// is cancelled with Unsubscribe or the provider is stopped.
+	// A blocked subscription holds up updates for all the others
+	BlockTimeout time.Duration
+	// ResyncOnDrop schedules a full resync every time an update is dropped
+	ResyncOnDrop bool
+	// ResumeFrom is the revision the consumer has applied last. Updates
+	// following it are replayed from the provider's history instead of
+	// sending a snapshot. If the history doesn't reach that far back
+	// a StateReset followed by a snapshot is sent. Zero means a snapshot
+	ResumeFrom uint64
+	// ResumeEpoch is the epoch of the revision to resume from. If it doesn't
+	// match the provider's one the revision comes from another Provider
+	// instance, a StateReset followed by a snapshot is sent then
+	ResumeEpoch string
+	// CycleMarkers encloses the updates of every fetch cycle with
+	// CycleStart and CycleEnd updates. Markers are never dropped,
+	// with DropNewest an extra buffer slot is reserved for CycleEnd.
+	// Ignored with the Coalesce and DropOldest policies as markers
+	// can't be merged or evicted
+	CycleMarkers bool
+}
+
+// deliver puts an update to the updates channel according to the delivery policy.
+// Returns false if the update has been dropped
+func (s *Subscription) deliver(update Update) bool {
+	select {
+	case <-s.quit:
+		// unsubscribed, the consumer is not reading anymore
+		return false
+	default:
+	}
+
+	if !s.flushMarkers() {
+		// updates can't overtake a pending cycle marker
+		s.dropped()
+		s.missed(update)
+		return false
+	}
+
+	switch s.opts.Delivery {
+	case Coalesce:
+		s.coalesce.push(update)
+		return true
+	case Block:
+		if s.wait(update) {
+			return true
+		}
+	case DropOldest:
+		for {
+			select {
+			case s.updates <- update:
+				return true
+			default:
+			}
+
+			if cap(s.updates) == 0 {
+				// nothing to evict from an unbuffered channel
+				break
+			}
+
+			select {
+			case evicted := <-s.updates:
+				// the evicted update has already been applied to the state,
+				// its object is caught up with on the next cycle
+				s.dropped()
+				s.missed(evicted)
+				if evicted.Type == StateReset {
+					// the consumer would keep the objects it has
+					s.Resync()
+				}
+			default:
+			}
+		}
+	default:
+		if s.markers && len(s.updates) >= cap(s.updates)-1 {
+			// the last slot is reserved for CycleEnd
+			break
+		}
+		select {
+		case s.updates <- update:
+			return true
+		default:
+		}
+	}
+
+	s.dropped()
+	s.missed(update)
+	return false
+}
+
+// wait sends the update waiting for the consumer up to BlockTimeout.
+// Returns false if the update hasn't been sent
+func (s *Subscription) wait(update Update) bool {
+	var timeout <-chan time.Time
+	if s.opts.BlockTimeout > 0 {
+		timer := time.NewTimer(s.opts.BlockTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case s.updates <- update:
+		return true
+	case <-timeout:
+	case <-s.done:
+	case <-s.quit:
+	}
+	return false
+}
+
+// putMarker sends a cycle marker without dropping it. With DropNewest
+// CycleStart is only sent if there's room for CycleEnd, which has
+// the last buffer slot reserved, so that the cycle can always be ended
+func (s *Subscription) putMarker(marker Update) bool {
+	if s.opts.Delivery == Block {
+		return s.wait(marker)
+	}
+	if marker.Type == CycleStart && len(s.updates) >= cap(s.updates)-1 {
+		return false
+	}
+	select {
+	case s.updates <- marker:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *Subscription) dropped() {
+	atomic.AddUint64(&s.dropCount, 1)
+	if s.opts.ResyncOnDrop {
+		s.Resync()
+	}
+}
+
+// Pending returns the number of updates waiting to be delivered
+// in addition to the ones buffered in the updates channel
+func (s *Subscription) Pending() int {
+	if s.coalesce == nil {
+		return 0
+	}
+	return s.coalesce.len()
+}
+
+// Dropped returns the number of updates dropped so far
+func (s *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropCount)
+}
+
+// Resync schedules a full resync of the subscription. On the next fetch
+// the subscription state is cleared, a StateReset update is sent
+// followed by ObjectAdd updates for all the current objects
+func (s *Subscription) Resync() {
+	atomic.StoreInt32(&s.resyncPending, 1)
+}
+
+func (s *Subscription) needsResync() bool {
+	return atomic.LoadInt32(&s.resyncPending) == 1
+}
+
+func (s *Subscription) needsSnapshot() bool {
+	return atomic.LoadInt32(&s.snapshotPending) == 1
+}
+
+// snapshot sends ObjectAdd updates for all the objects of the canonical
+// state to a freshly registered subscription. The state is empty
+// if the provider is not warm yet, the first fetch fills it up then.
+// The updates follow the delivery policy, dropped objects are sent
+// with the next fetch cycle
+func (s *Subscription) snapshot(state *State) {
+	if s.closed {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	atomic.StoreInt32(&s.snapshotPending, 0)
+	head := state.head()
+	s.sendMarker(CycleStart, head)
+	state.each(func(obj interface{}) {
+		update := newUpdate(ObjectAdd, obj)
+		update.Epoch = head.epoch
+		update.Revision = head.revision
+		s.apply(update)
+	})
+	s.sendMarker(CycleEnd, head)
+	s.state.setHead(head)
+}
+
+// resume replays the missed diffs to a freshly registered subscription
+// and brings its state to the canonical one
+func (s *Subscription) resume(cycles []*cycle, state *State) {
+	if s.closed {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	atomic.StoreInt32(&s.snapshotPending, 0)
+	for _, c := range cycles {
+		s.sendMarker(CycleStart, c)
+		for _, update := range c.updates {
+			s.replay(update)
+		}
+		s.sendMarker(CycleEnd, c)
+	}
+
+	s.state = newStateData()
+	state.each(func(obj interface{}) {
+		if s.accepts(obj) {
+			s.state.store(obj)
+		}
+	})
+	s.state.setHead(state.head())
+}
+
+// resync performs a scheduled resync against the canonical state
+func (s *Subscription) resync(state *State) {
+	if s.closed {
+		return
+	}
+
+	s.lock.Lock()
+	// clear the flag before sending so that drops during the resync
+	// schedule another one
+	atomic.StoreInt32(&s.resyncPending, 0)
+	if !s.deliver(Update{Type: StateReset, Epoch: state.Epoch, Revision: state.Revision}) {
+		// try again once the consumer has read the channel
+		s.Resync()
+		s.lock.Unlock()
+		return
+	}
+	s.state = newStateData()
+	s.missedObjects = make(map[string]missedObject)
+	s.lock.Unlock()
+
+	s.snapshot(state)
+}
@@ -0,0 +1,351 @@
+package vatspy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/viert/go-vatspy/dynamic"
+	"github.com/viert/go-vatspy/static"
+)
+
+// stateDiff applies fresh data to the canonical state
+// collecting the resulting updates
+type stateDiff struct {
+	state   *State
+	updates []Update
+}
+
+// newStateDiff starts a new fetch cycle bumping the state revision
+func newStateDiff(state *State) *stateDiff {
+	state.Revision++
+	return &stateDiff{state: state, updates: make([]Update, 0)}
+}
+
+// cycle returns the diff as a fetch cycle of the current state revision
+func (d *stateDiff) cycle() *cycle {
+	c := d.state.head()
+	c.updates = d.updates
+	return c
+}
+
+// apply stores an object in the state or removes it from there
+// and records the update with the previous version of the object
+func (d *stateDiff) apply(update Update) {
+	prev := d.state.lookup(update.Object)
+
+	switch {
+	case update.Type != ObjectRemove && prev == nil:
+		update.Type = ObjectAdd
+	case update.Type != ObjectRemove:
+		update.Type = ObjectModify
+		update.Previous = prev
+		update.Changes = changedFields(prev, update.Object)
+	case prev != nil:
+		update.Object = prev
+		update.Previous = prev
+	default:
+		return
+	}
+	update.Epoch = d.state.Epoch
+	update.Revision = d.state.Revision
+
+	if update.Type == ObjectRemove {
+		d.state.remove(update.Object)
+	} else {
+		d.state.store(update.Object)
+	}
+	d.updates = append(d.updates, update)
+}
+
+func (d *stateDiff) processStatic(data *static.Data) {
+	// process countries
+	for _, vsCountry := range data.Countries {
+		country := Country{
+			Country: vsCountry,
+		}
+
+		if existing, found := d.state.Countries[country.Prefix]; found {
+			if !country.equals(&existing) {
+				d.apply(newUpdate(ObjectModify, country))
+			}
+		} else {
+			d.apply(newUpdate(ObjectAdd, country))
+		}
+	}
+
+	for _, country := range d.state.Countries {
+		if data.FindCountryByPrefix(country.Prefix) == nil {
+			d.apply(newUpdate(ObjectRemove, country))
+		}
+	}
+
+	// process airports
+	for _, vsAirport := range data.Airports {
+		airport := Airport{
+			Airport: vsAirport,
+		}
+
+		if existing, found := d.state.Airports[airport.ICAO]; found {
+			airport.Controllers.ATIS = existing.Controllers.ATIS
+			airport.Controllers.Delivery = existing.Controllers.Delivery
+			airport.Controllers.Ground = existing.Controllers.Ground
+			airport.Controllers.Tower = existing.Controllers.Tower
+			airport.Controllers.Approach = existing.Controllers.Approach
+			if !airport.equals(&existing) {
+				d.apply(newUpdate(ObjectModify, airport))
+			}
+		} else {
+			d.apply(newUpdate(ObjectAdd, airport))
+		}
+	}
+
+	for _, airport := range d.state.Airports {
+		if data.FindAirportByICAO(airport.ICAO) == nil {
+			d.apply(newUpdate(ObjectRemove, airport))
+		}
+	}
+}
+
+func (d *stateDiff) processDynamic(dynamicData *dynamic.Data, staticData *static.Data) {
+	// process controllers
+	for _, vsController := range dynamicData.Controllers {
+		if vsController.Facility >= 2 && vsController.Facility <= 5 {
+			controller := AirportController{
+				Controller: vsController,
+			}
+
+			tokens := strings.Split(controller.Callsign, "_")
+			prefix := tokens[0]
+			vsAirport := staticData.FindAirport(prefix)
+			if vsAirport == nil {
+				postfix := tokens[len(tokens)-1]
+				if postfix != "OBS" && postfix != "SUP" {
+					log.Debugf("can't find airport named %s, the controller is %v", prefix, controller)
+				}
+				continue
+			}
+
+			if airport, found := d.state.Airports[vsAirport.ICAO]; found {
+				var existing *AirportController
+				airportModified := false
+
+				switch controller.Facility {
+				case 2:
+					existing = airport.Controllers.Delivery
+					if !existing.equals(&controller) {
+						airport.Controllers.Delivery = &controller
+						airportModified = true
+					}
+				case 3:
+					existing = airport.Controllers.Ground
+					if !existing.equals(&controller) {
+						airport.Controllers.Ground = &controller
+						airportModified = true
+					}
+				case 4:
+					existing = airport.Controllers.Tower
+					if !existing.equals(&controller) {
+						airport.Controllers.Tower = &controller
+						airportModified = true
+					}
+				case 5:
+					existing = airport.Controllers.Approach
+					if !existing.equals(&controller) {
+						airport.Controllers.Approach = &controller
+						airportModified = true
+					}
+				}
+				if airportModified {
+					d.apply(newUpdate(ObjectModify, airport))
+				}
+			} else {
+				airport = Airport{
+					Airport: *vsAirport,
+				}
+				switch controller.Facility {
+				case 2:
+					airport.Controllers.Delivery = &controller
+				case 3:
+					airport.Controllers.Ground = &controller
+				case 4:
+					airport.Controllers.Tower = &controller
+				case 5:
+					airport.Controllers.Approach = &controller
+				}
+				d.apply(newUpdate(ObjectAdd, airport))
+			}
+		} else if vsController.Facility == 6 {
+			// CTR
+			tokens := strings.Split(vsController.Callsign, "_")
+			prefix := tokens[0]
+			firs := make([]*FIR, 0)
+
+			fir := staticData.FindFIR(prefix)
+
+			if fir != nil {
+				firs = append(firs, &FIR{FIR: *fir})
+			} else {
+				uir := staticData.FindUIR(prefix)
+				if uir != nil {
+					for _, firID := range uir.FIRIDs {
+						fir = staticData.FindFIR(firID)
+						if fir != nil {
+							firs = append(firs, &FIR{FIR: *fir})
+						} else {
+							log.Debugf("can't find FIR %s provided by UIR %s", firID, uir.ID)
+						}
+					}
+				} else {
+					// silently catch special cases
+					postfix := tokens[len(tokens)-1]
+					if postfix == "OBS" || postfix == "SUP" {
+						continue
+					}
+
+					supervisorFound := false
+					for _, line := range vsController.TextAtis {
+						lowered := strings.ToLower(line)
+						if strings.Contains(lowered, "supervisor") {
+							supervisorFound = true
+							break
+						}
+					}
+
+					if supervisorFound {
+						continue
+					}
+
+					// no special cases found, log an error
+					log.Debugf("can't find FIR named %s, the controller is %v", prefix, vsController)
+					continue
+				}
+			}
+
+			if len(firs) == 0 {
+				log.Debugf("no FIRs or UIRs found by prefix %s for controller %v", prefix, vsController)
+				continue
+			}
+
+			radar := Radar{
+				Controller: vsController,
+				FIRs:       firs,
+			}
+
+			controlName := "Centre"
+			countryPrefix := fir.ID[:2]
+			country := staticData.FindCountryByPrefix(countryPrefix)
+			if country != nil && country.ControlCustomName != "" {
+				controlName = country.ControlCustomName
+			}
+			radar.HumanReadableName = fmt.Sprintf("%s %s", fir.Name, controlName)
+
+			if existing, found := d.state.Radars[radar.Callsign]; found {
+				if !existing.equals(&radar) {
+					d.apply(newUpdate(ObjectModify, radar))
+				}
+			} else {
+				d.apply(newUpdate(ObjectAdd, radar))
+			}
+		}
+	}
+
+	// process ATIS stations
+	for _, vsATIS := range dynamicData.ATIS {
+		atis := AirportController{
+			Controller: vsATIS,
+		}
+
+		tokens := strings.Split(atis.Callsign, "_")
+		prefix := tokens[0]
+		vsAirport := staticData.FindAirport(prefix)
+		if vsAirport == nil {
+			postfix := tokens[len(tokens)-1]
+			if postfix != "OBS" && postfix != "SUP" {
+				log.Debugf("can't find airport named %s, the controller is %v", prefix, atis)
+			}
+			continue
+		}
+		if airport, found := d.state.Airports[vsAirport.ICAO]; found {
+			existing := airport.Controllers.ATIS
+			if !existing.equals(&atis) {
+				airport.Controllers.ATIS = &atis
+				d.apply(newUpdate(ObjectModify, airport))
+			}
+		} else {
+			airport = Airport{
+				Airport: *vsAirport,
+			}
+			airport.Controllers.ATIS = &atis
+			d.apply(newUpdate(ObjectAdd, airport))
+		}
+	}
+
+	// Removing controllers
+	for key, airport := range d.state.Airports {
+		// a readonly copy to keep changed values
+		current := d.state.Airports[key]
+
+		var ctrl *AirportController
+		ctrl = airport.Controllers.ATIS
+		if ctrl != nil {
+			if dct := dynamicData.FindController(ctrl.Callsign); dct == nil {
+				airport.Controllers.ATIS = nil
+			}
+		}
+		ctrl = airport.Controllers.Delivery
+		if ctrl != nil {
+			if dct := dynamicData.FindController(ctrl.Callsign); dct == nil {
+				airport.Controllers.Delivery = nil
+			}
+		}
+		ctrl = airport.Controllers.Ground
+		if ctrl != nil {
+			if dct := dynamicData.FindController(ctrl.Callsign); dct == nil {
+				airport.Controllers.Ground = nil
+			}
+		}
+		ctrl = airport.Controllers.Tower
+		if ctrl != nil {
+			if dct := dynamicData.FindController(ctrl.Callsign); dct == nil {
+				airport.Controllers.Tower = nil
+			}
+		}
+		ctrl = airport.Controllers.Approach
+		if ctrl != nil {
+			if dct := dynamicData.FindController(ctrl.Callsign); dct == nil {
+				airport.Controllers.Approach = nil
+			}
+		}
+
+		if !current.equals(&airport) {
+			d.apply(newUpdate(ObjectModify, airport))
+		}
+	}
+
+	for callsign, radar := range d.state.Radars {
+		if ctrl := dynamicData.FindController(callsign); ctrl == nil {
+			d.apply(newUpdate(ObjectRemove, radar))
+		}
+	}
+
+	// process pilots
+	for _, vsPilot := range dynamicData.Pilots {
+		pilot := Pilot{
+			Pilot: vsPilot,
+		}
+
+		if existing, found := d.state.Pilots[pilot.Callsign]; found {
+			if !existing.equals(&pilot) {
+				d.apply(newUpdate(ObjectModify, pilot))
+			}
+		} else {
+			d.apply(newUpdate(ObjectAdd, pilot))
+		}
+	}
+
+	for callsign, pilot := range d.state.Pilots {
+		if dynamicData.FindPilot(callsign) == nil {
+			d.apply(newUpdate(ObjectRemove, pilot))
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package dynamic
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"time"
+)
 
 func newData(raw []byte) (*Data, error) {
 	var data Data
@@ -23,6 +26,11 @@ func newData(raw []byte) (*Data, error) {
 		data.ctrlCallsignMap[atis.Callsign] = &data.ATIS[i]
 	}
 
+	data.pilotCallsignMap = make(map[string]*Pilot)
+	for i, pilot := range data.Pilots {
+		data.pilotCallsignMap[pilot.Callsign] = &data.Pilots[i]
+	}
+
 	return &data, nil
 }
 
@@ -30,3 +38,13 @@ func newData(raw []byte) (*Data, error) {
 func (d *Data) FindController(cs string) *Controller {
 	return d.ctrlCallsignMap[cs]
 }
+
+// FindPilot finds a pilot by callsign
+func (d *Data) FindPilot(cs string) *Pilot {
+	return d.pilotCallsignMap[cs]
+}
+
+// UpdatedAt returns the time the data was generated at
+func (g *General) UpdatedAt() (time.Time, error) {
+	return time.Parse(time.RFC3339Nano, g.UpdateTimestamp)
+}
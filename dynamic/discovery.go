@@ -0,0 +1,185 @@
+package dynamic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/viert/go-vatspy/fetch"
+)
+
+// VatSimStatusURL is the VATSIM bootstrap document listing current data endpoints
+const VatSimStatusURL = "https://status.vatsim.net/status.json"
+
+// DefaultStatusTTL is how long a fetched status document is considered fresh
+const DefaultStatusTTL = time.Hour
+
+// SelectionStrategy defines how a data endpoint is chosen among the listed ones
+type SelectionStrategy int
+
+// SelectionStrategy enum definition
+const (
+	SelectRandom SelectionStrategy = iota
+	SelectRoundRobin
+)
+
+type (
+	// StatusData is a set of data endpoints published in the status document
+	StatusData struct {
+		V3              []string `json:"v3"`
+		Transceivers    []string `json:"transceivers"`
+		Servers         []string `json:"servers"`
+		ServersSweatbox []string `json:"servers_sweatbox"`
+		ServersAll      []string `json:"servers_all"`
+	}
+
+	// Status is a VATSIM status document
+	Status struct {
+		Data  StatusData `json:"data"`
+		User  []string   `json:"user"`
+		Metar []string   `json:"metar"`
+	}
+
+	// Discovery fetches and caches the VATSIM status document
+	// and picks v3 data endpoints from it, failing over to other
+	// endpoints when the chosen one errors
+	Discovery struct {
+		// StatusURL is the status document location
+		StatusURL string
+		// Strategy defines how the first endpoint to try is chosen
+		Strategy SelectionStrategy
+		// TTL is the status document cache lifetime
+		TTL time.Duration
+		// FallbackURL is used when the status document can't be fetched
+		// and there's no cached copy, empty string disables the fallback
+		FallbackURL string
+
+		lock      sync.Mutex
+		status    *Status
+		fetchedAt time.Time
+		next      int
+		rnd       *rand.Rand
+	}
+)
+
+// NewDiscovery creates a new Discovery using the public status document
+// with VatSimJSON3URL as a fallback
+func NewDiscovery(strategy SelectionStrategy) *Discovery {
+	return &Discovery{
+		StatusURL:   VatSimStatusURL,
+		Strategy:    strategy,
+		TTL:         DefaultStatusTTL,
+		FallbackURL: VatSimJSON3URL,
+	}
+}
+
+// Status returns the status document, fetching it if the cached copy is missing or expired.
+// If refreshing fails, the expired copy is returned along with no error
+func (d *Discovery) Status(ctx context.Context, client *fetch.Client) (*Status, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.getStatus(ctx, client)
+}
+
+func (d *Discovery) getStatus(ctx context.Context, client *fetch.Client) (*Status, error) {
+	if d.status != nil && time.Since(d.fetchedAt) < d.TTL {
+		return d.status, nil
+	}
+
+	raw, err := client.Get(ctx, d.StatusURL)
+	if err == nil {
+		var status Status
+		err = json.Unmarshal(raw, &status)
+		if err == nil {
+			d.status = &status
+			d.fetchedAt = time.Now()
+			return d.status, nil
+		}
+	}
+
+	if d.status != nil {
+		// stale is better than nothing
+		return d.status, nil
+	}
+	return nil, err
+}
+
+// Invalidate drops the cached status document so it's refetched on next use
+func (d *Discovery) Invalidate() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.status = nil
+}
+
+// Endpoints returns v3 data endpoints in the order they should be tried
+func (d *Discovery) Endpoints(ctx context.Context, client *fetch.Client) ([]string, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	status, err := d.getStatus(ctx, client)
+	if err != nil || len(status.Data.V3) == 0 {
+		if d.FallbackURL != "" {
+			return []string{d.FallbackURL}, nil
+		}
+		if err == nil {
+			err = fmt.Errorf("no v3 data endpoints listed in %s", d.StatusURL)
+		}
+		return nil, err
+	}
+
+	urls := status.Data.V3
+	var first int
+	switch d.Strategy {
+	case SelectRoundRobin:
+		first = d.next % len(urls)
+		d.next = first + 1
+	default:
+		if d.rnd == nil {
+			d.rnd = rand.New(rand.NewSource(time.Now().UnixNano()))
+		}
+		first = d.rnd.Intn(len(urls))
+	}
+
+	endpoints := make([]string, 0, len(urls))
+	for i := 0; i < len(urls); i++ {
+		endpoints = append(endpoints, urls[(first+i)%len(urls)])
+	}
+	return endpoints, nil
+}
+
+// Fetch fetches VATSIM data from one of the discovered endpoints,
+// trying the others if it fails. Every endpoint is tried once,
+// the client's retry policy applies to the endpoint list as a whole
+// so that a dead endpoint doesn't hold up failing over to the next one
+func (d *Discovery) Fetch(ctx context.Context, client *fetch.Client) (*Data, error) {
+	single := client.WithRetry(fetch.RetryPolicy{MaxAttempts: 1})
+
+	var data *Data
+	err := client.Policy().Do(ctx, func() error {
+		endpoints, err := d.Endpoints(ctx, single)
+		if err != nil {
+			return err
+		}
+
+		for _, url := range endpoints {
+			data, err = FetchContext(ctx, single, url)
+			if err == nil {
+				return nil
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+		}
+
+		// all the endpoints failed, the list is likely outdated
+		d.Invalidate()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
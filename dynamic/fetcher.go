@@ -1,8 +1,11 @@
 package dynamic
 
 import (
+	"context"
+	"io"
 	"io/ioutil"
-	"net/http"
+
+	"github.com/viert/go-vatspy/fetch"
 )
 
 const (
@@ -10,18 +13,35 @@ const (
 	VatSimJSON3URL = "https://data.vatsim.net/v3/vatsim-data.json"
 )
 
-// Fetch fetches raw data from VATSIM
-func Fetch(dataURL string) (*Data, error) {
-	resp, err := http.Get(dataURL)
+// Load loads and parses data from a local file
+func Load(filename string) (*Data, error) {
+	data, err := ioutil.ReadFile(filename)
 	if err != nil {
 		return nil, err
 	}
+	return newData(data)
+}
 
-	defer resp.Body.Close()
-	data, err := ioutil.ReadAll(resp.Body)
+// Read reads and parses data from an arbitrary reader
+func Read(r io.Reader) (*Data, error) {
+	data, err := ioutil.ReadAll(r)
 	if err != nil {
 		return nil, err
 	}
+	return newData(data)
+}
 
+// Fetch fetches raw data from VATSIM
+func Fetch(dataURL string) (*Data, error) {
+	return FetchContext(context.Background(), nil, dataURL)
+}
+
+// FetchContext fetches raw data from VATSIM using the given client.
+// A nil client is replaced with the default one
+func FetchContext(ctx context.Context, client *fetch.Client, dataURL string) (*Data, error) {
+	data, err := client.Get(ctx, dataURL)
+	if err != nil {
+		return nil, err
+	}
 	return newData(data)
 }
@@ -73,12 +73,13 @@ type (
 
 	// Data represents all the dynamic data with helper methods and index maps
 	Data struct {
-		General         General      `json:"general"`
-		Pilots          []Pilot      `json:"pilots"`
-		Controllers     []Controller `json:"controllers"`
-		ATIS            []Controller `json:"atis"`
-		Facilities      []Facility   `json:"facilities"`
-		facilityMap     map[int]*Facility
-		ctrlCallsignMap map[string]*Controller
+		General          General      `json:"general"`
+		Pilots           []Pilot      `json:"pilots"`
+		Controllers      []Controller `json:"controllers"`
+		ATIS             []Controller `json:"atis"`
+		Facilities       []Facility   `json:"facilities"`
+		facilityMap      map[int]*Facility
+		ctrlCallsignMap  map[string]*Controller
+		pilotCallsignMap map[string]*Pilot
 	}
 )
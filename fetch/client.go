@@ -0,0 +1,208 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// Default client settings
+const (
+	DefaultTimeout = 30 * time.Second
+)
+
+type (
+	// RetryPolicy describes how failed requests are retried
+	RetryPolicy struct {
+		// MaxAttempts is the total number of attempts including the first one
+		MaxAttempts int
+		// InitialBackoff is the delay before the first retry,
+		// every next delay is doubled
+		InitialBackoff time.Duration
+		// MaxBackoff caps the delay between retries
+		MaxBackoff time.Duration
+	}
+
+	// Client is an HTTP client wrapper checking response statuses
+	// and retrying failed requests with exponential backoff
+	Client struct {
+		HTTPClient *http.Client
+		Retry      RetryPolicy
+	}
+
+	// Validators are HTTP cache validators of a previously fetched response
+	Validators struct {
+		ETag         string
+		LastModified string
+	}
+
+	// StatusError is returned when a server responds with a non-2xx status
+	StatusError struct {
+		URL        string
+		StatusCode int
+		Status     string
+	}
+)
+
+var (
+	// ErrNotModified is returned by GetConditional when the server responds with 304
+	ErrNotModified = errors.New("not modified")
+
+	// DefaultRetryPolicy is used by clients created with NewClient
+	DefaultRetryPolicy = RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Second,
+		MaxBackoff:     30 * time.Second,
+	}
+
+	defaultClient = NewClient(nil)
+)
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected response status %s fetching %s", e.Status, e.URL)
+}
+
+// Temporary returns true if the request may succeed when retried
+func (e *StatusError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// NewClient creates a new Client with the default retry policy.
+// If httpClient is nil, a client with DefaultTimeout is used
+func NewClient(httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: DefaultTimeout}
+	}
+	return &Client{
+		HTTPClient: httpClient,
+		Retry:      DefaultRetryPolicy,
+	}
+}
+
+// Get fetches the given URL and returns the response body.
+// A nil Client is valid and behaves like a client created with NewClient(nil)
+func (c *Client) Get(ctx context.Context, url string) ([]byte, error) {
+	body, _, err := c.GetConditional(ctx, url, Validators{})
+	return body, err
+}
+
+// GetConditional fetches the given URL sending cache validators from a previous response.
+// ErrNotModified is returned if the content hasn't changed since.
+// New validators are returned along with the body
+func (c *Client) GetConditional(ctx context.Context, url string, validators Validators) ([]byte, Validators, error) {
+	if c == nil {
+		c = defaultClient
+	}
+
+	var body []byte
+	var received Validators
+	err := c.Retry.Do(ctx, func() error {
+		var err error
+		body, received, err = c.get(ctx, url, validators)
+		return err
+	})
+	return body, received, err
+}
+
+// Policy returns the client's retry policy.
+// A nil Client is valid and behaves like a client created with NewClient(nil)
+func (c *Client) Policy() RetryPolicy {
+	if c == nil {
+		c = defaultClient
+	}
+	return c.Retry
+}
+
+// WithRetry returns a copy of the client using the given retry policy.
+// A nil Client is valid and behaves like a client created with NewClient(nil)
+func (c *Client) WithRetry(policy RetryPolicy) *Client {
+	if c == nil {
+		c = defaultClient
+	}
+	cp := *c
+	cp.Retry = policy
+	return &cp
+}
+
+// Do calls attempt until it succeeds, fails with an error not worth retrying
+// or the attempts are exhausted, backing off exponentially in between
+func (p RetryPolicy) Do(ctx context.Context, attempt func() error) error {
+	attempts := p.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	backoff := p.InitialBackoff
+
+	for i := 1; ; i++ {
+		err := attempt()
+		if err == nil || i >= attempts || !retriable(ctx, err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+			backoff = p.MaxBackoff
+		}
+	}
+}
+
+func (c *Client) get(ctx context.Context, url string, validators Validators) ([]byte, Validators, error) {
+	var received Validators
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, received, err
+	}
+	if validators.ETag != "" {
+		req.Header.Set("If-None-Match", validators.ETag)
+	}
+	if validators.LastModified != "" {
+		req.Header.Set("If-Modified-Since", validators.LastModified)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, received, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, validators, ErrNotModified
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, received, &StatusError{URL: url, StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	received.ETag = resp.Header.Get("ETag")
+	received.LastModified = resp.Header.Get("Last-Modified")
+
+	body, err := ioutil.ReadAll(resp.Body)
+	return body, received, err
+}
+
+func retriable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || err == ErrNotModified {
+		// the caller gave up or there's nothing to retry
+		return false
+	}
+	if se, ok := err.(*StatusError); ok {
+		return se.Temporary()
+	}
+	// network errors are worth retrying
+	return true
+}
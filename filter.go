@@ -0,0 +1,217 @@
+package vatspy
+
+import (
+	"path"
+	"strings"
+
+	"github.com/viert/go-vatspy/static"
+)
+
+// The filters below match objects they are applicable to and reject the rest,
+// i.e. ByICAO rejects radars and pilots. Use Or and ByKind to let other kinds through.
+
+// And matches objects matched by all of the given filters
+func And(filters ...UpdateFilter) UpdateFilter {
+	return func(obj interface{}) bool {
+		for _, f := range filters {
+			if !f(obj) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or matches objects matched by any of the given filters
+func Or(filters ...UpdateFilter) UpdateFilter {
+	return func(obj interface{}) bool {
+		for _, f := range filters {
+			if f(obj) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not matches objects not matched by the given filter
+func Not(filter UpdateFilter) UpdateFilter {
+	return func(obj interface{}) bool {
+		return !filter(obj)
+	}
+}
+
+// ByKind matches objects of the given kinds
+func ByKind(kinds ...ObjectKind) UpdateFilter {
+	return func(obj interface{}) bool {
+		kind := kindOf(obj)
+		for _, k := range kinds {
+			if k == kind {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// ByCountry matches countries, airports and radars by country prefix,
+// i.e. "EG" matches the United Kingdom, EGLL airport and EGTT_CTR radar
+func ByCountry(prefixes ...string) UpdateFilter {
+	match := func(code string) bool {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(code, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+	return func(obj interface{}) bool {
+		switch o := obj.(type) {
+		case Country:
+			return match(o.Prefix)
+		case Airport:
+			return match(o.ICAO)
+		case Radar:
+			for _, fir := range o.FIRs {
+				if match(fir.ID) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
+
+// ByFIR matches airports located in and radars controlling the given FIRs
+func ByFIR(ids ...string) UpdateFilter {
+	match := func(id string) bool {
+		for _, fid := range ids {
+			if fid == id {
+				return true
+			}
+		}
+		return false
+	}
+	return func(obj interface{}) bool {
+		switch o := obj.(type) {
+		case Airport:
+			return match(o.FIRID)
+		case Radar:
+			for _, fir := range o.FIRs {
+				if match(fir.ID) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
+
+// ByICAO matches airports by ICAO code, patterns may contain
+// glob wildcards, i.e. "EG*" or "UU??"
+func ByICAO(patterns ...string) UpdateFilter {
+	return func(obj interface{}) bool {
+		if a, ok := obj.(Airport); ok {
+			return globMatch(patterns, a.ICAO)
+		}
+		return false
+	}
+}
+
+// ByCallsign matches radars and pilots by callsign and airports by
+// callsigns of their controllers, patterns may contain glob wildcards
+func ByCallsign(patterns ...string) UpdateFilter {
+	return func(obj interface{}) bool {
+		switch o := obj.(type) {
+		case Radar:
+			return globMatch(patterns, o.Callsign)
+		case Pilot:
+			return globMatch(patterns, o.Callsign)
+		case Airport:
+			for _, ctrl := range o.listControllers() {
+				if ctrl != nil && globMatch(patterns, ctrl.Callsign) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
+
+// ByFacility matches radars with the given facility types
+// and airports having a controller of any of the given facility types
+func ByFacility(facilities ...int) UpdateFilter {
+	return byController(func(ctrl *AirportController) bool {
+		return intsContain(facilities, ctrl.Facility)
+	})
+}
+
+// ByRating matches radars controlled with the given ratings and airports
+// having a controller with any of the given ratings
+func ByRating(ratings ...int) UpdateFilter {
+	return byController(func(ctrl *AirportController) bool {
+		return intsContain(ratings, ctrl.Rating)
+	})
+}
+
+// Controlled matches airports having at least one controller online and all radars
+func Controlled() UpdateFilter {
+	return byController(func(*AirportController) bool {
+		return true
+	})
+}
+
+// InBoundingBox matches airports and pilots located within the given box
+// and radars controlling a FIR whose bounding box overlaps it.
+// A box with min.Lng greater than max.Lng spans the antimeridian
+func InBoundingBox(min static.Point, max static.Point) UpdateFilter {
+	return func(obj interface{}) bool {
+		switch o := obj.(type) {
+		case Airport:
+			return o.Position.InBox(min, max)
+		case Pilot:
+			return static.Point{Lat: o.Latitude, Lng: o.Longitude}.InBox(min, max)
+		case Radar:
+			for _, fir := range o.FIRs {
+				if fir.OverlapsBox(min, max) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
+
+func byController(match func(*AirportController) bool) UpdateFilter {
+	return func(obj interface{}) bool {
+		switch o := obj.(type) {
+		case Radar:
+			return match(&AirportController{Controller: o.Controller})
+		case Airport:
+			for _, ctrl := range o.listControllers() {
+				if ctrl != nil && match(ctrl) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
+
+func globMatch(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, value); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func intsContain(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
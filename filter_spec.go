@@ -0,0 +1,361 @@
+package vatspy
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/viert/go-vatspy/static"
+)
+
+// FilterSpec is a declarative filter description which can be loaded
+// from JSON or parsed from a textual expression. All the non-empty
+// criteria of a single spec must match
+type FilterSpec struct {
+	And        []FilterSpec `json:"and,omitempty"`
+	Or         []FilterSpec `json:"or,omitempty"`
+	Not        *FilterSpec  `json:"not,omitempty"`
+	Kind       []string     `json:"kind,omitempty"`
+	Country    []string     `json:"country,omitempty"`
+	FIR        []string     `json:"fir,omitempty"`
+	ICAO       []string     `json:"icao,omitempty"`
+	Callsign   []string     `json:"callsign,omitempty"`
+	Facility   []int        `json:"facility,omitempty"`
+	Rating     []int        `json:"rating,omitempty"`
+	BBox       []float64    `json:"bbox,omitempty"` // min lat, min lng, max lat, max lng
+	Controlled bool         `json:"controlled,omitempty"`
+}
+
+var objectKindsByName = map[string]ObjectKind{
+	"airport": KindAirport,
+	"radar":   KindRadar,
+	"country": KindCountry,
+	"pilot":   KindPilot,
+}
+
+// Filter builds a filter function from the spec
+func (fs *FilterSpec) Filter() (UpdateFilter, error) {
+	filters := make([]UpdateFilter, 0)
+
+	if len(fs.And) > 0 {
+		sub, err := specFilters(fs.And)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, And(sub...))
+	}
+	if len(fs.Or) > 0 {
+		sub, err := specFilters(fs.Or)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, Or(sub...))
+	}
+	if fs.Not != nil {
+		f, err := fs.Not.Filter()
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, Not(f))
+	}
+	if len(fs.Kind) > 0 {
+		kinds := make([]ObjectKind, 0, len(fs.Kind))
+		for _, name := range fs.Kind {
+			kind, found := objectKindsByName[strings.ToLower(name)]
+			if !found {
+				return nil, fmt.Errorf("unknown object kind '%s'", name)
+			}
+			kinds = append(kinds, kind)
+		}
+		filters = append(filters, ByKind(kinds...))
+	}
+	if len(fs.Country) > 0 {
+		filters = append(filters, ByCountry(fs.Country...))
+	}
+	if len(fs.FIR) > 0 {
+		filters = append(filters, ByFIR(fs.FIR...))
+	}
+	if len(fs.ICAO) > 0 {
+		filters = append(filters, ByICAO(fs.ICAO...))
+	}
+	if len(fs.Callsign) > 0 {
+		filters = append(filters, ByCallsign(fs.Callsign...))
+	}
+	if len(fs.Facility) > 0 {
+		filters = append(filters, ByFacility(fs.Facility...))
+	}
+	if len(fs.Rating) > 0 {
+		filters = append(filters, ByRating(fs.Rating...))
+	}
+	if len(fs.BBox) > 0 {
+		if len(fs.BBox) != 4 {
+			return nil, fmt.Errorf("bbox must have 4 values, got %d", len(fs.BBox))
+		}
+		filters = append(filters, InBoundingBox(
+			static.Point{Lat: fs.BBox[0], Lng: fs.BBox[1]},
+			static.Point{Lat: fs.BBox[2], Lng: fs.BBox[3]},
+		))
+	}
+	if fs.Controlled {
+		filters = append(filters, Controlled())
+	}
+
+	if len(filters) == 1 {
+		return filters[0], nil
+	}
+	return And(filters...), nil
+}
+
+func specFilters(specs []FilterSpec) ([]UpdateFilter, error) {
+	filters := make([]UpdateFilter, 0, len(specs))
+	for i := range specs {
+		f, err := specs[i].Filter()
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, f)
+	}
+	return filters, nil
+}
+
+// FilterFromJSON builds a filter from a JSON-encoded FilterSpec
+func FilterFromJSON(data []byte) (UpdateFilter, error) {
+	var spec FilterSpec
+	err := json.Unmarshal(data, &spec)
+	if err != nil {
+		return nil, err
+	}
+	return spec.Filter()
+}
+
+// ParseFilter builds a filter from a textual expression, i.e.
+//
+//	country(EG, EI) and not facility(6) or kind(pilot) and bbox(49, -11, 61, 2)
+//
+// Supported criteria are kind, country, fir, icao, callsign, facility, rating,
+// bbox and controlled, combined with and, or, not and parentheses.
+// "and" takes precedence over "or"
+func ParseFilter(expr string) (UpdateFilter, error) {
+	spec, err := ParseFilterSpec(expr)
+	if err != nil {
+		return nil, err
+	}
+	return spec.Filter()
+}
+
+// ParseFilterSpec parses a textual filter expression into a FilterSpec,
+// see ParseFilter for the syntax
+func ParseFilterSpec(expr string) (*FilterSpec, error) {
+	p := &filterParser{tokens: tokenizeFilter(expr)}
+	spec, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok != "" {
+		return nil, fmt.Errorf("unexpected '%s' in filter expression", tok)
+	}
+	return spec, nil
+}
+
+type filterParser struct {
+	tokens []string
+	pos    int
+}
+
+func tokenizeFilter(expr string) []string {
+	tokens := make([]string, 0)
+	current := strings.Builder{}
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+	for _, r := range expr {
+		switch r {
+		case ' ', '\t', '\n', '\r':
+			flush()
+		case '(', ')', ',':
+			flush()
+			tokens = append(tokens, string(r))
+		default:
+			current.WriteRune(r)
+		}
+	}
+	flush()
+	return tokens
+}
+
+func (p *filterParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *filterParser) next() string {
+	tok := p.peek()
+	if tok != "" {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *filterParser) expect(tok string) error {
+	if got := p.next(); got != tok {
+		if got == "" {
+			return fmt.Errorf("expected '%s' but the filter expression ended", tok)
+		}
+		return fmt.Errorf("expected '%s', got '%s' in filter expression", tok, got)
+	}
+	return nil
+}
+
+func (p *filterParser) parseOr() (*FilterSpec, error) {
+	spec, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	specs := []FilterSpec{*spec}
+	for strings.ToLower(p.peek()) == "or" {
+		p.next()
+		spec, err = p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, *spec)
+	}
+	if len(specs) == 1 {
+		return &specs[0], nil
+	}
+	return &FilterSpec{Or: specs}, nil
+}
+
+func (p *filterParser) parseAnd() (*FilterSpec, error) {
+	spec, err := p.parseFactor()
+	if err != nil {
+		return nil, err
+	}
+	specs := []FilterSpec{*spec}
+	for strings.ToLower(p.peek()) == "and" {
+		p.next()
+		spec, err = p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, *spec)
+	}
+	if len(specs) == 1 {
+		return &specs[0], nil
+	}
+	return &FilterSpec{And: specs}, nil
+}
+
+func (p *filterParser) parseFactor() (*FilterSpec, error) {
+	tok := p.next()
+	switch strings.ToLower(tok) {
+	case "":
+		return nil, fmt.Errorf("unexpected end of filter expression")
+	case "not":
+		spec, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		return &FilterSpec{Not: spec}, nil
+	case "(":
+		spec, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return spec, p.expect(")")
+	case "controlled":
+		if p.peek() == "(" {
+			p.next()
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+		}
+		return &FilterSpec{Controlled: true}, nil
+	}
+
+	args, err := p.parseArgs()
+	if err != nil {
+		return nil, err
+	}
+
+	spec := &FilterSpec{}
+	switch strings.ToLower(tok) {
+	case "kind":
+		spec.Kind = args
+	case "country":
+		spec.Country = args
+	case "fir":
+		spec.FIR = args
+	case "icao":
+		spec.ICAO = args
+	case "callsign":
+		spec.Callsign = args
+	case "facility":
+		spec.Facility, err = parseInts(args)
+	case "rating":
+		spec.Rating, err = parseInts(args)
+	case "bbox":
+		spec.BBox, err = parseFloats(args)
+	default:
+		return nil, fmt.Errorf("unknown filter criterion '%s'", tok)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+func (p *filterParser) parseArgs() ([]string, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	args := make([]string, 0)
+	for {
+		tok := p.next()
+		switch tok {
+		case "":
+			return nil, fmt.Errorf("unexpected end of filter expression")
+		case "(", ")", ",":
+			return nil, fmt.Errorf("unexpected '%s' in filter arguments", tok)
+		}
+		args = append(args, tok)
+
+		tok = p.next()
+		if tok == ")" {
+			return args, nil
+		}
+		if tok != "," {
+			return nil, fmt.Errorf("expected ',' or ')' in filter arguments, got '%s'", tok)
+		}
+	}
+}
+
+func parseInts(args []string) ([]int, error) {
+	values := make([]int, 0, len(args))
+	for _, arg := range args {
+		v, err := strconv.Atoi(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer '%s' in filter arguments", arg)
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func parseFloats(args []string) ([]float64, error) {
+	values := make([]float64, 0, len(args))
+	for _, arg := range args {
+		v, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number '%s' in filter arguments", arg)
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
@@ -0,0 +1,167 @@
+package vatspy
+
+import (
+	"sort"
+
+	"github.com/viert/go-vatspy/geojson"
+	"github.com/viert/go-vatspy/static"
+)
+
+func controllerSummary(ctrl *AirportController) geojson.Properties {
+	summary := geojson.Properties{
+		"callsign":  ctrl.Callsign,
+		"frequency": ctrl.Frequency,
+		"name":      ctrl.Name,
+	}
+	if ctrl.AtisCode != "" {
+		summary["atis_code"] = ctrl.AtisCode
+	}
+	return summary
+}
+
+// Feature returns the airport as a GeoJSON point feature with a summary
+// of the controllers online keyed by facility (ATIS, DEL, GND, TWR, APP)
+func (a *Airport) Feature() geojson.Feature {
+	props := a.Airport.Properties()
+	props["kind"] = KindAirport.String()
+
+	controllers := make(geojson.Properties)
+	slots := map[string]*AirportController{
+		"ATIS": a.Controllers.ATIS,
+		"DEL":  a.Controllers.Delivery,
+		"GND":  a.Controllers.Ground,
+		"TWR":  a.Controllers.Tower,
+		"APP":  a.Controllers.Approach,
+	}
+	for facility, ctrl := range slots {
+		if ctrl != nil {
+			controllers[facility] = controllerSummary(ctrl)
+		}
+	}
+	props["controllers"] = controllers
+	props["controlled"] = len(controllers) > 0
+
+	return geojson.NewFeature(a.ICAO, geojson.NewPoint(a.Position.Position()), props)
+}
+
+// Features returns the radar as GeoJSON features, one per boundaries record
+// of its FIRs as the records (regular, oceanic, extension) may overlap.
+// Features are identified by the callsign followed by FIR.FeatureID,
+// i.e. "EGTT_CTR/EGTT/0". If none of the FIRs has boundaries a single
+// feature without geometry identified by the callsign is returned
+func (r *Radar) Features() []geojson.Feature {
+	firIDs := make([]string, 0, len(r.FIRs))
+	for _, fir := range r.FIRs {
+		firIDs = append(firIDs, fir.ID)
+	}
+	properties := func() geojson.Properties {
+		return geojson.Properties{
+			"kind":      KindRadar.String(),
+			"callsign":  r.Callsign,
+			"frequency": r.Frequency,
+			"facility":  r.Facility,
+			"name":      r.Name,
+			"title":     r.HumanReadableName,
+			"fir_ids":   firIDs,
+		}
+	}
+
+	features := make([]geojson.Feature, 0, len(r.FIRs))
+	for _, fir := range r.FIRs {
+		for j := range fir.AllBoundaries {
+			bnds := &fir.AllBoundaries[j]
+			geometry := bnds.Geometry()
+			if geometry == nil {
+				continue
+			}
+			props := properties()
+			props["fir_id"] = fir.ID
+			props["is_oceanic"] = bnds.IsOceanic
+			props["is_extension"] = bnds.IsExtension
+			features = append(features, geojson.NewFeature(r.Callsign+"/"+fir.FeatureID(j), geometry, props))
+		}
+	}
+
+	if len(features) == 0 {
+		features = append(features, geojson.NewFeature(r.Callsign, nil, properties()))
+	}
+	return features
+}
+
+// Feature returns the pilot as a GeoJSON point feature
+func (p *Pilot) Feature() geojson.Feature {
+	props := geojson.Properties{
+		"kind":        KindPilot.String(),
+		"callsign":    p.Callsign,
+		"cid":         p.Cid,
+		"name":        p.Name,
+		"altitude":    p.Altitude,
+		"groundspeed": p.Groundspeed,
+		"heading":     p.Heading,
+		"transponder": p.Transponder,
+	}
+	if fp := p.FlightPlan; fp != nil {
+		props["aircraft"] = fp.Aircraft
+		props["departure"] = fp.Departure
+		props["arrival"] = fp.Arrival
+		props["flight_rules"] = fp.FlightRules
+	}
+	pos := static.Point{Lat: p.Latitude, Lng: p.Longitude}
+	return geojson.NewFeature(p.Callsign, geojson.NewPoint(pos.Position()), props)
+}
+
+// AirportsGeoJSON returns the state airports as a GeoJSON FeatureCollection
+func (s *State) AirportsGeoJSON() *geojson.FeatureCollection {
+	fc := geojson.NewFeatureCollection()
+	keys := make([]string, 0, len(s.Airports))
+	for key := range s.Airports {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		airport := s.Airports[key]
+		fc.Add(airport.Feature())
+	}
+	return fc
+}
+
+// RadarsGeoJSON returns the state radars as a GeoJSON FeatureCollection
+// with a feature per boundaries record of their FIRs
+func (s *State) RadarsGeoJSON() *geojson.FeatureCollection {
+	fc := geojson.NewFeatureCollection()
+	keys := make([]string, 0, len(s.Radars))
+	for key := range s.Radars {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		radar := s.Radars[key]
+		fc.Add(radar.Features()...)
+	}
+	return fc
+}
+
+// PilotsGeoJSON returns the state pilots as a GeoJSON FeatureCollection
+func (s *State) PilotsGeoJSON() *geojson.FeatureCollection {
+	fc := geojson.NewFeatureCollection()
+	keys := make([]string, 0, len(s.Pilots))
+	for key := range s.Pilots {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		pilot := s.Pilots[key]
+		fc.Add(pilot.Feature())
+	}
+	return fc
+}
+
+// GeoJSON returns all the state airports, radars and pilots as a single
+// GeoJSON FeatureCollection, the "kind" property tells them apart
+func (s *State) GeoJSON() *geojson.FeatureCollection {
+	fc := geojson.NewFeatureCollection()
+	fc.Add(s.AirportsGeoJSON().Features...)
+	fc.Add(s.RadarsGeoJSON().Features...)
+	fc.Add(s.PilotsGeoJSON().Features...)
+	return fc
+}
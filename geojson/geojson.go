@@ -0,0 +1,116 @@
+package geojson
+
+// GeoJSON object types
+const (
+	TypePoint             = "Point"
+	TypePolygon           = "Polygon"
+	TypeMultiPolygon      = "MultiPolygon"
+	TypeFeature           = "Feature"
+	TypeFeatureCollection = "FeatureCollection"
+)
+
+type (
+	// Position is a GeoJSON position, longitude goes first
+	Position [2]float64
+
+	// Geometry is a GeoJSON geometry object
+	Geometry struct {
+		Type        string      `json:"type"`
+		Coordinates interface{} `json:"coordinates"`
+	}
+
+	// Properties is a set of GeoJSON feature properties
+	Properties map[string]interface{}
+
+	// Feature is a GeoJSON feature object
+	Feature struct {
+		Type       string     `json:"type"`
+		ID         string     `json:"id,omitempty"`
+		Geometry   *Geometry  `json:"geometry"`
+		Properties Properties `json:"properties"`
+	}
+
+	// FeatureCollection is a GeoJSON feature collection object
+	FeatureCollection struct {
+		Type     string    `json:"type"`
+		Features []Feature `json:"features"`
+	}
+)
+
+// NewPosition creates a new position from latitude and longitude
+func NewPosition(lat float64, lng float64) Position {
+	return Position{lng, lat}
+}
+
+// NewPoint creates a new Point geometry
+func NewPoint(pos Position) *Geometry {
+	return &Geometry{Type: TypePoint, Coordinates: pos}
+}
+
+// NewPolygon creates a new Polygon geometry from the exterior ring and holes.
+// Rings are closed and wound according to the right-hand rule
+// (exterior counterclockwise, holes clockwise) if needed
+func NewPolygon(exterior []Position, holes ...[]Position) *Geometry {
+	return &Geometry{Type: TypePolygon, Coordinates: polygonRings(exterior, holes)}
+}
+
+// NewMultiPolygon creates a new MultiPolygon geometry from polygons
+// given as exterior rings
+func NewMultiPolygon(exteriors ...[]Position) *Geometry {
+	polygons := make([][][]Position, 0, len(exteriors))
+	for _, exterior := range exteriors {
+		polygons = append(polygons, polygonRings(exterior, nil))
+	}
+	return &Geometry{Type: TypeMultiPolygon, Coordinates: polygons}
+}
+
+// NewFeature creates a new Feature, properties may be nil
+func NewFeature(id string, geometry *Geometry, properties Properties) Feature {
+	if properties == nil {
+		properties = make(Properties)
+	}
+	return Feature{Type: TypeFeature, ID: id, Geometry: geometry, Properties: properties}
+}
+
+// NewFeatureCollection creates a new empty FeatureCollection
+func NewFeatureCollection() *FeatureCollection {
+	return &FeatureCollection{Type: TypeFeatureCollection, Features: make([]Feature, 0)}
+}
+
+// Add appends features to the collection
+func (fc *FeatureCollection) Add(features ...Feature) {
+	fc.Features = append(fc.Features, features...)
+}
+
+func polygonRings(exterior []Position, holes [][]Position) [][]Position {
+	rings := make([][]Position, 0, len(holes)+1)
+	rings = append(rings, ring(exterior, true))
+	for _, hole := range holes {
+		rings = append(rings, ring(hole, false))
+	}
+	return rings
+}
+
+// ring returns a closed copy of the ring wound in the requested direction
+func ring(positions []Position, ccw bool) []Position {
+	r := make([]Position, len(positions), len(positions)+1)
+	copy(r, positions)
+	if len(r) > 0 && r[0] != r[len(r)-1] {
+		r = append(r, r[0])
+	}
+	if (signedArea(r) > 0) != ccw {
+		for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+			r[i], r[j] = r[j], r[i]
+		}
+	}
+	return r
+}
+
+// signedArea is positive for counterclockwise rings
+func signedArea(r []Position) float64 {
+	var area float64
+	for i := 0; i+1 < len(r); i++ {
+		area += r[i][0]*r[i+1][1] - r[i+1][0]*r[i][1]
+	}
+	return area / 2
+}
@@ -0,0 +1,109 @@
+package vatspy
+
+import (
+	"fmt"
+	"time"
+)
+
+// Fetch sources reported in FetchError
+const (
+	SourceStatic  = "static"
+	SourceDynamic = "dynamic"
+)
+
+type (
+	// FetchError is an error occurred while loading static or dynamic data
+	FetchError struct {
+		Source string
+		Err    error
+	}
+
+	// ErrorCallback is a callback function called on every fetch error.
+	// It's called from the provider's loop so it must not block
+	ErrorCallback func(err error)
+
+	// Health is a provider health status
+	Health struct {
+		// LastStaticFetch is the time of the last successful static data load
+		LastStaticFetch time.Time `json:"last_static_fetch"`
+		// LastDynamicFetch is the time of the last successful dynamic data load
+		LastDynamicFetch time.Time `json:"last_dynamic_fetch"`
+		// LastFeedUpdate is the generation time of the most recent dynamic data
+		LastFeedUpdate time.Time `json:"last_feed_update"`
+		// StaticFailures is the number of consecutive static load failures
+		StaticFailures int `json:"static_failures"`
+		// DynamicFailures is the number of consecutive dynamic load failures
+		DynamicFailures int `json:"dynamic_failures"`
+		// LastError is the most recent fetch error, if any
+		LastError error `json:"-"`
+		// LastErrorAt is the time of the most recent fetch error
+		LastErrorAt time.Time `json:"last_error_at"`
+		// FeedStale is true if the dynamic feed has stopped advancing
+		FeedStale bool `json:"feed_stale"`
+	}
+)
+
+func (e *FetchError) Error() string {
+	return fmt.Sprintf("error loading %s data: %s", e.Source, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *FetchError) Unwrap() error {
+	return e.Err
+}
+
+// Ready returns true if both static and dynamic data have been loaded
+// and the dynamic feed is not stale
+func (h Health) Ready() bool {
+	return !h.LastStaticFetch.IsZero() && !h.LastDynamicFetch.IsZero() && !h.FeedStale
+}
+
+func (p *Provider) reportFetch(source string, err error) {
+	now := time.Now()
+
+	p.lock.Lock()
+	if err == nil {
+		switch source {
+		case SourceStatic:
+			p.health.LastStaticFetch = now
+			p.health.StaticFailures = 0
+		case SourceDynamic:
+			p.health.LastDynamicFetch = now
+			p.health.DynamicFailures = 0
+		}
+		p.lock.Unlock()
+		return
+	}
+
+	if p.ctx.Err() != nil {
+		// the provider is shutting down, aborted fetches are expected
+		p.lock.Unlock()
+		return
+	}
+
+	err = &FetchError{Source: source, Err: err}
+	switch source {
+	case SourceStatic:
+		p.health.StaticFailures++
+	case SourceDynamic:
+		p.health.DynamicFailures++
+	}
+	p.health.LastError = err
+	p.health.LastErrorAt = now
+	p.lock.Unlock()
+
+	log.Debugf("%s", err)
+	if p.opts.Error != nil {
+		p.opts.Error(err)
+	}
+}
+
+// Health returns the provider's health status
+func (p *Provider) Health() Health {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	h := p.health
+	h.LastFeedUpdate = p.feedUpdatedAt
+	h.FeedStale = p.feedStale
+	return h
+}
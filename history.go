@@ -0,0 +1,69 @@
+package vatspy
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"time"
+)
+
+// newEpoch generates a random provider instance identifier
+func newEpoch() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(buf)
+}
+
+// cycle is a canonical diff of a single fetch cycle
+type cycle struct {
+	epoch     string
+	revision  uint64
+	timestamp time.Time
+	updates   []Update
+}
+
+// history keeps canonical diffs of the most recent fetch cycles
+// so that subscriptions can resume from a known revision
+type history struct {
+	size   int
+	cycles []*cycle
+}
+
+func newHistory(size int) *history {
+	return &history{size: size, cycles: make([]*cycle, 0, size)}
+}
+
+// add records a fetch cycle evicting the oldest one if needed
+func (h *history) add(c *cycle) {
+	if h.size <= 0 {
+		return
+	}
+	if len(h.cycles) == h.size {
+		copy(h.cycles, h.cycles[1:])
+		h.cycles = h.cycles[:len(h.cycles)-1]
+	}
+	h.cycles = append(h.cycles, c)
+}
+
+// since returns the cycles following the given revision up to the current one.
+// Returns false if some of them have already been evicted or the revision
+// is unknown, i.e. comes from another Provider instance
+func (h *history) since(revision uint64, current uint64) ([]*cycle, bool) {
+	if revision > current {
+		return nil, false
+	}
+	if revision == current {
+		return nil, true
+	}
+	if len(h.cycles) == 0 || h.cycles[0].revision > revision+1 {
+		return nil, false
+	}
+	for i, c := range h.cycles {
+		if c.revision == revision+1 {
+			return h.cycles[i:], true
+		}
+	}
+	return nil, false
+}
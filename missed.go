@@ -0,0 +1,100 @@
+package vatspy
+
+// missedObject is an object the consumer has missed an update of
+type missedObject struct {
+	// object is any version of the object, it identifies the object
+	// in the canonical state
+	object interface{}
+	// seen is the version the consumer is known to have, nil if none
+	seen interface{}
+}
+
+// missed records the object of an update which hasn't reached the consumer
+// so that its current version is sent with the next fetch cycle.
+// Updates of the object are held back until then
+func (s *Subscription) missed(update Update) {
+	key := update.Key()
+	if key == "" {
+		return
+	}
+	if _, found := s.missedObjects[key]; found {
+		// the consumer still has the version recorded first
+		return
+	}
+
+	var seen interface{}
+	switch update.Type {
+	case ObjectModify:
+		seen = update.Previous
+	case ObjectRemove:
+		seen = update.Object
+	}
+	s.missedObjects[key] = missedObject{object: update.Object, seen: seen}
+}
+
+// isMissed returns true if the update's object is waiting to be caught up with
+func (s *Subscription) isMissed(update Update) bool {
+	_, found := s.missedObjects[update.Key()]
+	return found
+}
+
+// catchUp sends the current version of every missed object from the canonical
+// state. It stops at the first undelivered update leaving the rest for
+// the next cycle. Returns the objects handled, the cycle's updates
+// of them are obsolete as the canonical state already includes them
+func (s *Subscription) catchUp(c *cycle, state *State) map[string]missedObject {
+	if len(s.missedObjects) == 0 {
+		return nil
+	}
+
+	handled := s.missedObjects
+	s.missedObjects = make(map[string]missedObject)
+	blocked := false
+	for key, m := range handled {
+		if blocked {
+			s.missedObjects[key] = m
+			continue
+		}
+
+		current := state.lookup(m.object)
+		visible := current != nil && s.accepts(current)
+
+		var update Update
+		switch {
+		case visible && m.seen == nil:
+			update = newUpdate(ObjectAdd, current)
+		case visible:
+			changes := changedFields(m.seen, current)
+			if len(changes) == 0 {
+				// the consumer has the current version already
+				s.state.store(current)
+				continue
+			}
+			update = newUpdate(ObjectModify, current)
+			update.Previous = m.seen
+			update.Changes = changes
+		case m.seen != nil:
+			update = newUpdate(ObjectRemove, m.seen)
+			update.Previous = m.seen
+		default:
+			// invisible to the consumer before and after
+			s.state.remove(m.object)
+			continue
+		}
+		update.Epoch = c.epoch
+		update.Revision = c.revision
+
+		if !s.sendUpdate(update) {
+			// the update is recorded as missed again, there's no point
+			// in trying the rest until the consumer reads the channel
+			blocked = true
+			continue
+		}
+		if update.Type == ObjectRemove {
+			s.state.remove(update.Object)
+		} else {
+			s.state.store(update.Object)
+		}
+	}
+	return handled
+}
@@ -0,0 +1,101 @@
+package vatspy
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/viert/go-vatspy/dynamic"
+	"github.com/viert/go-vatspy/fetch"
+	"github.com/viert/go-vatspy/static"
+)
+
+// Default Provider settings used when an Options field is left empty
+const (
+	DefaultStaticUpdatePeriod  = 24 * time.Hour
+	DefaultDynamicUpdatePeriod = time.Minute
+	DefaultStaleFeedThreshold  = 5 * time.Minute
+
+	DefaultMinDynamicUpdatePeriod = 15 * time.Second
+	DefaultMaxDynamicUpdatePeriod = 5 * time.Minute
+
+	DefaultHistorySize = 60
+)
+
+// Options is a Provider configuration
+type Options struct {
+	// StaticUpdatePeriod is how often VATSpy static data is reloaded
+	StaticUpdatePeriod time.Duration
+	// DynamicUpdatePeriod is how often VATSim dynamic data is reloaded
+	DynamicUpdatePeriod time.Duration
+	// StaticReady is called once static data is initially loaded
+	StaticReady ReadyCallback
+	// StaticSource is where static data is loaded from,
+	// defaults to the VATSpy public repository
+	StaticSource StaticSource
+	// DynamicSource is where dynamic data is loaded from,
+	// defaults to the VATSim public JSON3 API discovered via status.json
+	DynamicSource DynamicSource
+	// HTTPClient is used by HTTP-based sources,
+	// defaults to a client with fetch.DefaultTimeout
+	HTTPClient *http.Client
+	// Retry is a retry policy for HTTP requests,
+	// fetch.DefaultRetryPolicy is used if MaxAttempts is zero
+	Retry fetch.RetryPolicy
+	// StaleFeedThreshold is how long the feed's update timestamp may stay
+	// the same before the feed is considered stale
+	StaleFeedThreshold time.Duration
+	// StaleFeed is called when the feed becomes stale and when it recovers
+	StaleFeed StaleFeedCallback
+	// Error is called on every static or dynamic fetch error
+	Error ErrorCallback
+	// AdaptivePolling makes the provider follow the reload period advertised
+	// by the feed instead of DynamicUpdatePeriod
+	AdaptivePolling bool
+	// MinDynamicUpdatePeriod and MaxDynamicUpdatePeriod bound the advertised
+	// reload period in adaptive polling mode
+	MinDynamicUpdatePeriod time.Duration
+	MaxDynamicUpdatePeriod time.Duration
+	// PollJitter is the upper bound of a random delay added to every dynamic poll
+	// so that multiple instances don't hit the feed in lockstep
+	PollJitter time.Duration
+	// HistorySize is the number of recent fetch cycles kept
+	// for resuming subscriptions
+	HistorySize int
+}
+
+func (o Options) withDefaults() Options {
+	if o.StaticUpdatePeriod <= 0 {
+		o.StaticUpdatePeriod = DefaultStaticUpdatePeriod
+	}
+	if o.DynamicUpdatePeriod <= 0 {
+		o.DynamicUpdatePeriod = DefaultDynamicUpdatePeriod
+	}
+	if o.StaticSource == nil {
+		o.StaticSource = StaticURLs(static.VATSpyDataPublicURL, static.FIRBoundariesPublicURL)
+	}
+	if o.DynamicSource == nil {
+		o.DynamicSource = DynamicDiscovery(dynamic.NewDiscovery(dynamic.SelectRandom))
+	}
+	if o.MinDynamicUpdatePeriod <= 0 {
+		o.MinDynamicUpdatePeriod = DefaultMinDynamicUpdatePeriod
+	}
+	if o.MaxDynamicUpdatePeriod <= 0 {
+		o.MaxDynamicUpdatePeriod = DefaultMaxDynamicUpdatePeriod
+	}
+	if o.MaxDynamicUpdatePeriod < o.MinDynamicUpdatePeriod {
+		o.MaxDynamicUpdatePeriod = o.MinDynamicUpdatePeriod
+	}
+	if o.StaleFeedThreshold <= 0 {
+		o.StaleFeedThreshold = DefaultStaleFeedThreshold
+	}
+	if o.HTTPClient == nil {
+		o.HTTPClient = &http.Client{Timeout: fetch.DefaultTimeout}
+	}
+	if o.HistorySize <= 0 {
+		o.HistorySize = DefaultHistorySize
+	}
+	if o.Retry.MaxAttempts == 0 {
+		o.Retry = fetch.DefaultRetryPolicy
+	}
+	return o
+}
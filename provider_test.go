@@ -0,0 +1,613 @@
+package vatspy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/op/go-logging"
+	"github.com/viert/go-vatspy/dynamic"
+)
+
+func init() {
+	logging.SetLevel(logging.WARNING, "vatspy")
+}
+
+const testStaticData = `[Countries]
+United Kingdom|EG|
+Russia|UU|Control
+
+[Airports]
+EGLL|London Heathrow|51.4775|-0.461389|LHR|EGTT|0
+EGKK|London Gatwick|51.148056|-0.190278|LGW|EGTT|0
+UUEE|Moscow Sheremetyevo|55.972642|37.414589|SVO|UUWV|0
+
+[FIRs]
+EGTT|London|EGTT|EGTT
+UUWV|Moscow|UUWV|UUWV
+
+[UIRs]
+`
+
+const testBoundaries = `EGTT|0|0|4|50|-6|56|2|53|-2
+50|-6
+56|-6
+56|2
+50|2
+UUWV|0|0|4|53|33|58|41|55|37
+53|33
+58|33
+58|41
+53|41
+`
+
+// testFeed is a dynamic feed advancing only when the test changes it
+type testFeed struct {
+	lock        sync.Mutex
+	updatedAt   time.Time
+	pilots      map[string]dynamic.Pilot
+	controllers map[string]dynamic.Controller
+}
+
+func newTestFeed() *testFeed {
+	return &testFeed{
+		updatedAt:   time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		pilots:      make(map[string]dynamic.Pilot),
+		controllers: make(map[string]dynamic.Controller),
+	}
+}
+
+// update changes the feed and advances its update timestamp
+func (f *testFeed) update(cb func(f *testFeed)) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if cb != nil {
+		cb(f)
+	}
+	f.updatedAt = f.updatedAt.Add(15 * time.Second)
+}
+
+func (f *testFeed) setPilot(callsign string, lat float64, lng float64, altitude int) {
+	f.pilots[callsign] = dynamic.Pilot{Cid: 1, Callsign: callsign, Latitude: lat, Longitude: lng, Altitude: altitude}
+}
+
+func (f *testFeed) setController(callsign string, facility int, frequency string) {
+	f.controllers[callsign] = dynamic.Controller{Cid: 2, Callsign: callsign, Facility: facility, Frequency: frequency}
+}
+
+func (f *testFeed) reader() (io.ReadCloser, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	data := dynamic.Data{
+		General:     dynamic.General{Version: 3, UpdateTimestamp: f.updatedAt.Format(time.RFC3339)},
+		Pilots:      make([]dynamic.Pilot, 0, len(f.pilots)),
+		Controllers: make([]dynamic.Controller, 0, len(f.controllers)),
+		ATIS:        make([]dynamic.Controller, 0),
+	}
+	for _, pilot := range f.pilots {
+		data.Pilots = append(data.Pilots, pilot)
+	}
+	for _, ctrl := range f.controllers {
+		data.Controllers = append(data.Controllers, ctrl)
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(raw)), nil
+}
+
+func stringReader(s string) ReaderFactory {
+	return func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader(s)), nil
+	}
+}
+
+// runTestProvider starts a provider polling the feed and waits
+// for the static data and the first feed version to be loaded
+func runTestProvider(t *testing.T, feed *testFeed, opts Options) *Provider {
+	opts.StaticSource = StaticReaders(stringReader(testStaticData), stringReader(testBoundaries))
+	opts.DynamicSource = DynamicReader(feed.reader)
+	opts.DynamicUpdatePeriod = 5 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p, err := Run(ctx, opts)
+	if err != nil {
+		cancel()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cancel()
+		p.Wait()
+	})
+	waitRevision(t, p, 2)
+	return p
+}
+
+// advance changes the feed and waits for the provider to apply it
+func advance(t *testing.T, p *Provider, feed *testFeed, cb func(f *testFeed)) {
+	revision := p.Revision()
+	feed.update(cb)
+	waitRevision(t, p, revision+1)
+}
+
+// waitSnapshot waits for the subscription to get the snapshot sent
+func waitSnapshot(t *testing.T, sub *Subscription) {
+	deadline := time.Now().Add(2 * time.Second)
+	for sub.GetState().Revision == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("subscription %d has got no snapshot", sub.subID)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func waitRevision(t *testing.T, p *Provider, revision uint64) {
+	deadline := time.Now().Add(2 * time.Second)
+	for p.Revision() < revision {
+		if time.Now().After(deadline) {
+			t.Fatalf("provider is at revision %d, want %d", p.Revision(), revision)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// consumer applies received updates the way a subscriber would
+type consumer struct {
+	objects map[string]interface{}
+	updates []Update
+}
+
+func newConsumer() *consumer {
+	return &consumer{objects: make(map[string]interface{})}
+}
+
+func (c *consumer) apply(update Update) {
+	c.updates = append(c.updates, update)
+	switch update.Type {
+	case StateReset:
+		c.objects = make(map[string]interface{})
+	case ObjectAdd, ObjectModify:
+		c.objects[update.Key()] = update.Object
+	case ObjectRemove:
+		delete(c.objects, update.Key())
+	}
+}
+
+// read applies the updates until none arrive for a while
+func (c *consumer) read(sub *Subscription) {
+	for {
+		select {
+		case update, ok := <-sub.Updates():
+			if !ok {
+				return
+			}
+			c.apply(update)
+		case <-time.After(50 * time.Millisecond):
+			return
+		}
+	}
+}
+
+func (c *consumer) keys() []string {
+	keys := make([]string, 0, len(c.objects))
+	for key := range c.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func stateKeys(state *State) []string {
+	keys := make([]string, 0)
+	state.each(func(obj interface{}) {
+		keys = append(keys, newUpdate(ObjectAdd, obj).Key())
+	})
+	sort.Strings(keys)
+	return keys
+}
+
+func assertKeys(t *testing.T, what string, got []string, want ...string) {
+	t.Helper()
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("%s has %v, want %v", what, got, want)
+	}
+}
+
+func TestDroppedRemoveIsCaughtUp(t *testing.T) {
+	feed := newTestFeed()
+	feed.update(func(f *testFeed) {
+		f.setPilot("AAA", 51, 0, 1000)
+		f.setPilot("BBB", 52, 0, 2000)
+	})
+	p := runTestProvider(t, feed, Options{})
+
+	sub := p.Subscribe(2, false, ByKind(KindPilot))
+	waitSnapshot(t, sub)
+	c := newConsumer()
+	// the buffer is full with the snapshot, both updates are dropped
+	advance(t, p, feed, func(f *testFeed) {
+		delete(f.pilots, "BBB")
+		f.setPilot("AAA", 51, 1, 1500)
+	})
+	if sub.Dropped() != 2 {
+		t.Fatalf("%d updates dropped, want 2", sub.Dropped())
+	}
+	c.read(sub)
+	assertKeys(t, "consumer", c.keys(), "pilot:AAA", "pilot:BBB")
+
+	advance(t, p, feed, nil)
+	c.read(sub)
+	assertKeys(t, "consumer", c.keys(), "pilot:AAA")
+	assertKeys(t, "subscription state", stateKeys(sub.GetState()), "pilot:AAA")
+
+	last := c.updates[len(c.updates)-1]
+	if aaa, _ := c.objects["pilot:AAA"].(Pilot); aaa.Altitude != 1500 {
+		t.Errorf("AAA altitude is %d, want 1500", aaa.Altitude)
+	}
+	if last.Revision != p.Revision() {
+		t.Errorf("caught up update revision is %d, want %d", last.Revision, p.Revision())
+	}
+}
+
+func TestDroppedStaticAddIsCaughtUp(t *testing.T) {
+	feed := newTestFeed()
+	p := runTestProvider(t, feed, Options{})
+
+	sub := p.Subscribe(1, false, ByKind(KindCountry))
+	waitSnapshot(t, sub)
+	c := newConsumer()
+	c.read(sub)
+	if sub.Dropped() != 1 {
+		t.Fatalf("%d updates dropped, want 1", sub.Dropped())
+	}
+
+	advance(t, p, feed, nil)
+	c.read(sub)
+	assertKeys(t, "consumer", c.keys(), "country:EG", "country:UU")
+	assertKeys(t, "subscription state", stateKeys(sub.GetState()), "country:EG", "country:UU")
+}
+
+func TestIdleSubscriberDoesNotHoldUpProvider(t *testing.T) {
+	feed := newTestFeed()
+	p := runTestProvider(t, feed, Options{})
+
+	idle := p.Subscribe(1, false)
+	sub := p.Subscribe(100, false, ByKind(KindPilot))
+	waitSnapshot(t, idle)
+	c := newConsumer()
+
+	advance(t, p, feed, func(f *testFeed) {
+		f.setPilot("AAA", 51, 0, 1000)
+	})
+	advance(t, p, feed, func(f *testFeed) {
+		f.setPilot("BBB", 52, 0, 2000)
+	})
+	c.read(sub)
+	assertKeys(t, "consumer", c.keys(), "pilot:AAA", "pilot:BBB")
+	if idle.Dropped() == 0 {
+		t.Errorf("idle subscription has no updates dropped")
+	}
+}
+
+func TestEvictedObjectIsCaughtUp(t *testing.T) {
+	feed := newTestFeed()
+	feed.update(func(f *testFeed) {
+		f.setPilot("AAA", 51, 0, 1000)
+		f.setPilot("BBB", 52, 0, 2000)
+	})
+	p := runTestProvider(t, feed, Options{})
+
+	sub := p.SubscribeWithOptions(SubscriptionOptions{
+		ChanSize: 2,
+		Delivery: DropOldest,
+		Filters:  []UpdateFilter{ByKind(KindPilot)},
+	})
+	waitSnapshot(t, sub)
+	c := newConsumer()
+	advance(t, p, feed, func(f *testFeed) {
+		f.setPilot("CCC", 53, 0, 3000)
+	})
+	c.read(sub)
+	if sub.Dropped() != 1 {
+		t.Fatalf("%d updates dropped, want 1", sub.Dropped())
+	}
+	if len(c.keys()) != 2 {
+		t.Fatalf("consumer has %v, want 2 pilots", c.keys())
+	}
+
+	advance(t, p, feed, nil)
+	c.read(sub)
+	assertKeys(t, "consumer", c.keys(), "pilot:AAA", "pilot:BBB", "pilot:CCC")
+	for _, update := range c.updates {
+		if update.Type == StateReset {
+			t.Errorf("eviction has caused a resync")
+		}
+	}
+}
+
+// assertBatches checks that every update is enclosed with cycle markers
+// of the same revision
+func assertBatches(t *testing.T, updates []Update) {
+	t.Helper()
+	var start *Update
+	for i := range updates {
+		update := &updates[i]
+		switch update.Type {
+		case CycleStart:
+			if start != nil {
+				t.Fatalf("update %d starts revision %d before revision %d has ended", i, update.Revision, start.Revision)
+			}
+			start = update
+		case CycleEnd:
+			if start == nil || start.Revision != update.Revision {
+				t.Fatalf("update %d ends revision %d which hasn't started", i, update.Revision)
+			}
+			start = nil
+		default:
+			if start == nil || start.Revision != update.Revision {
+				t.Fatalf("%s %s of revision %d is outside of its cycle", update.Type, update.Key(), update.Revision)
+			}
+		}
+	}
+	if start != nil {
+		t.Fatalf("revision %d hasn't ended", start.Revision)
+	}
+}
+
+func TestCycleMarkersAreNotDropped(t *testing.T) {
+	feed := newTestFeed()
+	feed.update(func(f *testFeed) {
+		f.setPilot("AAA", 51, 0, 1000)
+		f.setPilot("BBB", 52, 0, 2000)
+		f.setPilot("CCC", 53, 0, 3000)
+	})
+	p := runTestProvider(t, feed, Options{})
+
+	sub := p.SubscribeWithOptions(SubscriptionOptions{
+		ChanSize:     2,
+		CycleMarkers: true,
+		Filters:      []UpdateFilter{ByKind(KindPilot)},
+	})
+	waitSnapshot(t, sub)
+	// the buffer is full, the cycle doesn't start for the consumer
+	advance(t, p, feed, func(f *testFeed) {
+		f.setPilot("AAA", 51, 1, 1500)
+	})
+
+	c := newConsumer()
+	for i := 0; i < 5 && len(c.objects) < 3; i++ {
+		c.read(sub)
+		advance(t, p, feed, nil)
+	}
+	c.read(sub)
+
+	assertBatches(t, c.updates)
+	assertKeys(t, "consumer", c.keys(), "pilot:AAA", "pilot:BBB", "pilot:CCC")
+	if aaa, _ := c.objects["pilot:AAA"].(Pilot); aaa.Altitude != 1500 {
+		t.Errorf("AAA altitude is %d, want 1500", aaa.Altitude)
+	}
+}
+
+func TestLateSubscriberSnapshot(t *testing.T) {
+	feed := newTestFeed()
+	feed.update(func(f *testFeed) {
+		f.setPilot("AAA", 51, 0, 1000)
+		f.setController("EGLL_TWR", 4, "118.500")
+		f.setController("EGTT_CTR", 6, "127.100")
+	})
+	p := runTestProvider(t, feed, Options{})
+
+	sub := p.Subscribe(100, false)
+	c := newConsumer()
+	c.read(sub)
+	assertKeys(t, "consumer", c.keys(), stateKeys(p.GetState())...)
+	assertKeys(t, "subscription state", stateKeys(sub.GetState()), stateKeys(p.GetState())...)
+	for _, update := range c.updates {
+		if update.Type != ObjectAdd || update.Revision != p.Revision() {
+			t.Errorf("snapshot has %s %s of revision %d", update.Type, update.Key(), update.Revision)
+		}
+	}
+	if egll, _ := c.objects["airport:EGLL"].(Airport); egll.Controllers.Tower == nil {
+		t.Errorf("EGLL has no tower controller in the snapshot")
+	}
+
+	// live diffs follow the snapshot
+	advance(t, p, feed, func(f *testFeed) {
+		f.setPilot("BBB", 52, 0, 2000)
+	})
+	c.read(sub)
+	if _, found := c.objects["pilot:BBB"]; !found {
+		t.Errorf("consumer hasn't got BBB added after the snapshot")
+	}
+}
+
+func TestCoalesceCancelsAddRemove(t *testing.T) {
+	feed := newTestFeed()
+	feed.update(func(f *testFeed) {
+		f.setPilot("AAA", 51, 0, 1000)
+	})
+	p := runTestProvider(t, feed, Options{})
+
+	sub := p.SubscribeWithOptions(SubscriptionOptions{
+		Delivery: Coalesce,
+		Filters:  []UpdateFilter{ByKind(KindPilot)},
+	})
+	waitSnapshot(t, sub)
+	// wait for the pump to take the snapshot update from the queue,
+	// it's stuck sending it as nobody is reading
+	deadline := time.Now().Add(2 * time.Second)
+	for sub.Pending() > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	advance(t, p, feed, func(f *testFeed) {
+		f.setPilot("AAA", 51, 1, 1500)
+		f.setPilot("BBB", 52, 0, 2000)
+	})
+	advance(t, p, feed, func(f *testFeed) {
+		f.setPilot("AAA", 51, 2, 2500)
+		delete(f.pilots, "BBB")
+	})
+	if sub.Pending() != 1 {
+		t.Errorf("%d updates pending, want 1", sub.Pending())
+	}
+
+	c := newConsumer()
+	c.read(sub)
+	if len(c.updates) != 2 {
+		t.Fatalf("consumer has got %d updates, want 2", len(c.updates))
+	}
+	modify := c.updates[1]
+	aaa, _ := modify.Pilot()
+	prev, _ := modify.PreviousPilot()
+	if modify.Type != ObjectModify || aaa.Altitude != 2500 || prev.Altitude != 1000 {
+		t.Errorf("got %s of AAA from altitude %d to %d, want modify from 1000 to 2500", modify.Type, prev.Altitude, aaa.Altitude)
+	}
+	if sub.Dropped() != 0 {
+		t.Errorf("%d updates dropped, want none", sub.Dropped())
+	}
+}
+
+func TestFilterScopedState(t *testing.T) {
+	feed := newTestFeed()
+	feed.update(func(f *testFeed) {
+		f.setPilot("AAA", 51, 0, 1000)
+		f.setController("EGTT_CTR", 6, "127.100")
+	})
+	p := runTestProvider(t, feed, Options{})
+
+	sub := p.Subscribe(100, false, ByCallsign("EGLL_*"))
+	c := newConsumer()
+	c.read(sub)
+	assertKeys(t, "subscription state", stateKeys(sub.GetState()))
+
+	advance(t, p, feed, func(f *testFeed) {
+		f.setController("EGLL_TWR", 4, "118.500")
+	})
+	c.read(sub)
+	assertKeys(t, "consumer", c.keys(), "airport:EGLL")
+	assertKeys(t, "subscription state", stateKeys(sub.GetState()), "airport:EGLL")
+	if add := c.updates[len(c.updates)-1]; add.Type != ObjectAdd {
+		t.Errorf("EGLL getting a matching controller is sent as %s, want add", add.Type)
+	}
+
+	advance(t, p, feed, func(f *testFeed) {
+		delete(f.controllers, "EGLL_TWR")
+	})
+	c.read(sub)
+	assertKeys(t, "consumer", c.keys())
+	assertKeys(t, "subscription state", stateKeys(sub.GetState()))
+	if _, found := p.GetState().Airports["EGLL"]; !found {
+		t.Errorf("EGLL is missing from the canonical state")
+	}
+}
+
+func TestResume(t *testing.T) {
+	feed := newTestFeed()
+	feed.update(func(f *testFeed) {
+		f.setPilot("AAA", 51, 0, 1000)
+	})
+	p := runTestProvider(t, feed, Options{})
+
+	opts := SubscriptionOptions{
+		ChanSize:     100,
+		CycleMarkers: true,
+		Filters:      []UpdateFilter{ByKind(KindPilot)},
+	}
+	sub := p.SubscribeWithOptions(opts)
+	c := newConsumer()
+	c.read(sub)
+	last := c.updates[len(c.updates)-1]
+	if last.Type != CycleEnd {
+		t.Fatalf("snapshot ends with %s, want %s", last.Type, CycleEnd)
+	}
+	p.Unsubscribe(sub)
+
+	advance(t, p, feed, func(f *testFeed) {
+		f.setPilot("BBB", 52, 0, 2000)
+	})
+	advance(t, p, feed, func(f *testFeed) {
+		f.setPilot("AAA", 51, 1, 1500)
+	})
+	advance(t, p, feed, func(f *testFeed) {
+		delete(f.pilots, "BBB")
+	})
+
+	t.Run("from history", func(t *testing.T) {
+		resumed := *c
+		resumed.updates = nil
+		resumed.objects = make(map[string]interface{})
+		for key, obj := range c.objects {
+			resumed.objects[key] = obj
+		}
+
+		opts := opts
+		opts.ResumeFrom = last.Revision
+		opts.ResumeEpoch = last.Epoch
+		sub := p.SubscribeWithOptions(opts)
+		resumed.read(sub)
+
+		assertBatches(t, resumed.updates)
+		starts := 0
+		for _, update := range resumed.updates {
+			switch update.Type {
+			case CycleStart:
+				starts++
+			case StateReset:
+				t.Errorf("resumed subscription has got a reset")
+			}
+		}
+		if want := int(p.Revision() - last.Revision); starts != want {
+			t.Errorf("%d cycles replayed, want %d", starts, want)
+		}
+		assertKeys(t, "consumer", resumed.keys(), "pilot:AAA")
+		assertKeys(t, "subscription state", stateKeys(sub.GetState()), "pilot:AAA")
+		if aaa, _ := resumed.objects["pilot:AAA"].(Pilot); aaa.Altitude != 1500 {
+			t.Errorf("AAA altitude is %d, want 1500", aaa.Altitude)
+		}
+	})
+
+	t.Run("epoch mismatch", func(t *testing.T) {
+		stale := newConsumer()
+		stale.objects["pilot:BBB"] = Pilot{}
+
+		opts := opts
+		opts.ResumeFrom = last.Revision
+		opts.ResumeEpoch = "another"
+		sub := p.SubscribeWithOptions(opts)
+		stale.read(sub)
+
+		if len(stale.updates) == 0 || stale.updates[0].Type != StateReset {
+			t.Fatalf("subscription resumed from another epoch hasn't got a reset first")
+		}
+		assertBatches(t, stale.updates[1:])
+		assertKeys(t, "consumer", stale.keys(), "pilot:AAA")
+	})
+}
+
+func TestRadarFeatures(t *testing.T) {
+	feed := newTestFeed()
+	feed.update(func(f *testFeed) {
+		f.setController("EGTT_CTR", 6, "127.100")
+	})
+	p := runTestProvider(t, feed, Options{})
+
+	fc := p.GetState().RadarsGeoJSON()
+	if len(fc.Features) != 1 {
+		t.Fatalf("got %d radar features, want 1", len(fc.Features))
+	}
+	feature := fc.Features[0]
+	if feature.ID != "EGTT_CTR/EGTT/0" || feature.Properties["fir_id"] != "EGTT" {
+		t.Errorf("radar feature is %v with FIR %v, want EGTT_CTR/EGTT/0 with EGTT", feature.ID, feature.Properties["fir_id"])
+	}
+}
@@ -0,0 +1,146 @@
+package vatspy
+
+import (
+	"context"
+	"io"
+
+	"github.com/viert/go-vatspy/dynamic"
+	"github.com/viert/go-vatspy/fetch"
+	"github.com/viert/go-vatspy/static"
+)
+
+type (
+	// StaticSource is a source of VATSpy static data.
+	// The client is the Provider's HTTP client, sources not using HTTP ignore it.
+	// A source may return static.ErrNotModified to indicate the data is the same
+	// as on the previous load
+	StaticSource interface {
+		LoadStatic(ctx context.Context, client *fetch.Client) (*static.Data, error)
+	}
+
+	// DynamicSource is a source of VATSim dynamic data.
+	// The client is the Provider's HTTP client, sources not using HTTP ignore it
+	DynamicSource interface {
+		LoadDynamic(ctx context.Context, client *fetch.Client) (*dynamic.Data, error)
+	}
+
+	// ReaderFactory opens a fresh reader every time the data is (re)loaded.
+	// The reader is closed by the source after use.
+	ReaderFactory func() (io.ReadCloser, error)
+
+	staticURLSource struct {
+		fetcher *static.Fetcher
+	}
+
+	staticFileSource struct {
+		dataFilename       string
+		boundariesFilename string
+	}
+
+	staticReaderSource struct {
+		data       ReaderFactory
+		boundaries ReaderFactory
+	}
+
+	dynamicURLSource struct {
+		dataURL string
+	}
+
+	dynamicDiscoverySource struct {
+		discovery *dynamic.Discovery
+	}
+
+	dynamicFileSource struct {
+		filename string
+	}
+
+	dynamicReaderSource struct {
+		data ReaderFactory
+	}
+)
+
+// StaticURLs creates a static source fetching VATSpy data over HTTP.
+// The source uses conditional requests and returns static.ErrNotModified
+// if the data hasn't changed since the previous load
+func StaticURLs(dataURL string, boundariesURL string) StaticSource {
+	return &staticURLSource{fetcher: static.NewFetcher(dataURL, boundariesURL)}
+}
+
+// StaticFiles creates a static source loading VATSpy data from local files
+func StaticFiles(dataFilename string, boundariesFilename string) StaticSource {
+	return &staticFileSource{dataFilename: dataFilename, boundariesFilename: boundariesFilename}
+}
+
+// StaticReaders creates a static source reading VATSpy data from readers
+// produced by the given factories
+func StaticReaders(data ReaderFactory, boundaries ReaderFactory) StaticSource {
+	return &staticReaderSource{data: data, boundaries: boundaries}
+}
+
+// DynamicURL creates a dynamic source fetching VATSim data over HTTP
+func DynamicURL(dataURL string) DynamicSource {
+	return &dynamicURLSource{dataURL: dataURL}
+}
+
+// DynamicDiscovery creates a dynamic source picking data endpoints
+// from the VATSIM status document
+func DynamicDiscovery(discovery *dynamic.Discovery) DynamicSource {
+	return &dynamicDiscoverySource{discovery: discovery}
+}
+
+// DynamicFile creates a dynamic source loading VATSim data from a local file
+func DynamicFile(filename string) DynamicSource {
+	return &dynamicFileSource{filename: filename}
+}
+
+// DynamicReader creates a dynamic source reading VATSim data from readers
+// produced by the given factory
+func DynamicReader(data ReaderFactory) DynamicSource {
+	return &dynamicReaderSource{data: data}
+}
+
+func (s *staticURLSource) LoadStatic(ctx context.Context, client *fetch.Client) (*static.Data, error) {
+	return s.fetcher.Fetch(ctx, client)
+}
+
+func (s *staticFileSource) LoadStatic(ctx context.Context, client *fetch.Client) (*static.Data, error) {
+	return static.Load(s.dataFilename, s.boundariesFilename)
+}
+
+func (s *staticReaderSource) LoadStatic(ctx context.Context, client *fetch.Client) (*static.Data, error) {
+	dr, err := s.data()
+	if err != nil {
+		return nil, err
+	}
+	defer dr.Close()
+
+	br, err := s.boundaries()
+	if err != nil {
+		return nil, err
+	}
+	defer br.Close()
+
+	return static.Read(dr, br)
+}
+
+func (s *dynamicURLSource) LoadDynamic(ctx context.Context, client *fetch.Client) (*dynamic.Data, error) {
+	return dynamic.FetchContext(ctx, client, s.dataURL)
+}
+
+func (s *dynamicDiscoverySource) LoadDynamic(ctx context.Context, client *fetch.Client) (*dynamic.Data, error) {
+	return s.discovery.Fetch(ctx, client)
+}
+
+func (s *dynamicFileSource) LoadDynamic(ctx context.Context, client *fetch.Client) (*dynamic.Data, error) {
+	return dynamic.Load(s.filename)
+}
+
+func (s *dynamicReaderSource) LoadDynamic(ctx context.Context, client *fetch.Client) (*dynamic.Data, error) {
+	r, err := s.data()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return dynamic.Read(r)
+}
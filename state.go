@@ -1,6 +1,8 @@
 package vatspy
 
 import (
+	"time"
+
 	"github.com/viert/go-vatspy/dynamic"
 	"github.com/viert/go-vatspy/static"
 )
@@ -43,11 +45,23 @@ type (
 		static.Country
 	}
 
+	// Pilot is a VatSim pilot
+	Pilot struct {
+		dynamic.Pilot
+	}
+
 	// State is a subscription state
 	State struct {
 		Airports  map[string]Airport `json:"airports"`
 		Countries map[string]Country `json:"countries"`
 		Radars    map[string]Radar   `json:"radars"`
+		Pilots    map[string]Pilot   `json:"pilots"`
+		// Revision is the revision of the last fetch cycle applied to the state
+		Revision uint64 `json:"revision"`
+		// Epoch identifies the Provider instance the revision belongs to
+		Epoch string `json:"epoch"`
+		// UpdatedAt is the generation time of the dynamic data applied last
+		UpdatedAt time.Time `json:"updated_at"`
 	}
 )
 
@@ -104,24 +118,9 @@ func (c *Radar) equals(other *Radar) bool {
 		c.VisualRange == other.VisualRange &&
 		c.AtisCode == other.AtisCode &&
 		c.LogonTime == other.LogonTime {
-		if len(c.TextAtis) == len(other.TextAtis) {
-			for i := 0; i < len(c.TextAtis); i++ {
-				if c.TextAtis[i] != other.TextAtis[i] {
-					return false
-				}
-			}
-		} else {
-			return false
-		}
-
-		if len(c.FIRs) == len(other.FIRs) {
-			for i := 0; i < len(c.FIRs); i++ {
-				if !c.FIRs[i].equals(other.FIRs[i]) {
-					return false
-				}
-				return true
-			}
-		}
+		return c.HumanReadableName == other.HumanReadableName &&
+			stringsEqual(c.TextAtis, other.TextAtis) &&
+			firsEqual(c.FIRs, other.FIRs)
 	}
 	return false
 }
@@ -189,6 +188,31 @@ func (a *Airport) equals(other *Airport) bool {
 		a.Controllers == other.Controllers
 }
 
+func (p *Pilot) equals(other *Pilot) bool {
+	if p == nil {
+		return other == nil
+	}
+	if other == nil {
+		return false
+	}
+
+	return flightPlansEqual(p.FlightPlan, other.FlightPlan) &&
+		p.Cid == other.Cid &&
+		p.Name == other.Name &&
+		p.Callsign == other.Callsign &&
+		p.Server == other.Server &&
+		p.PilotRating == other.PilotRating &&
+		p.Latitude == other.Latitude &&
+		p.Longitude == other.Longitude &&
+		p.Altitude == other.Altitude &&
+		p.Groundspeed == other.Groundspeed &&
+		p.Transponder == other.Transponder &&
+		p.Heading == other.Heading &&
+		p.QnhIHg == other.QnhIHg &&
+		p.QnhMb == other.QnhMb &&
+		p.LogonTime == other.LogonTime
+}
+
 // IsEmpty returns true if the airport has no controllers online
 func (a *Airport) IsEmpty() bool {
 	c := a.Controllers
@@ -210,5 +234,110 @@ func newStateData() *State {
 		Airports:  make(map[string]Airport),
 		Countries: make(map[string]Country),
 		Radars:    make(map[string]Radar),
+		Pilots:    make(map[string]Pilot),
+	}
+}
+
+// Copy returns a copy of the state which is safe to use while the original
+// is being updated. Objects are never modified in place once stored so
+// copying the maps is enough to get an immutable snapshot
+func (s *State) Copy() *State {
+	c := &State{
+		Airports:  make(map[string]Airport, len(s.Airports)),
+		Countries: make(map[string]Country, len(s.Countries)),
+		Radars:    make(map[string]Radar, len(s.Radars)),
+		Pilots:    make(map[string]Pilot, len(s.Pilots)),
+		Revision:  s.Revision,
+		Epoch:     s.Epoch,
+		UpdatedAt: s.UpdatedAt,
+	}
+	for k, v := range s.Airports {
+		c.Airports[k] = v
+	}
+	for k, v := range s.Countries {
+		c.Countries[k] = v
+	}
+	for k, v := range s.Radars {
+		c.Radars[k] = v
 	}
+	for k, v := range s.Pilots {
+		c.Pilots[k] = v
+	}
+	return c
+}
+
+// store puts an object to the state replacing the existing version
+func (s *State) store(obj interface{}) {
+	switch o := obj.(type) {
+	case Airport:
+		s.Airports[o.ICAO] = o
+	case Radar:
+		s.Radars[o.Callsign] = o
+	case Country:
+		s.Countries[o.Prefix] = o
+	case Pilot:
+		s.Pilots[o.Callsign] = o
+	}
+}
+
+// remove deletes an object from the state
+func (s *State) remove(obj interface{}) {
+	switch o := obj.(type) {
+	case Airport:
+		delete(s.Airports, o.ICAO)
+	case Radar:
+		delete(s.Radars, o.Callsign)
+	case Country:
+		delete(s.Countries, o.Prefix)
+	case Pilot:
+		delete(s.Pilots, o.Callsign)
+	}
+}
+
+// lookup returns the stored version of the given object or nil
+func (s *State) lookup(obj interface{}) interface{} {
+	var prev interface{}
+	var found bool
+	switch o := obj.(type) {
+	case Airport:
+		prev, found = s.Airports[o.ICAO]
+	case Radar:
+		prev, found = s.Radars[o.Callsign]
+	case Country:
+		prev, found = s.Countries[o.Prefix]
+	case Pilot:
+		prev, found = s.Pilots[o.Callsign]
+	}
+	if !found {
+		return nil
+	}
+	return prev
+}
+
+// each calls the callback for every object in the state
+func (s *State) each(cb func(obj interface{})) {
+	for _, country := range s.Countries {
+		cb(country)
+	}
+	for _, airport := range s.Airports {
+		cb(airport)
+	}
+	for _, radar := range s.Radars {
+		cb(radar)
+	}
+	for _, pilot := range s.Pilots {
+		cb(pilot)
+	}
+}
+
+// head returns an empty cycle describing the last applied fetch cycle
+func (s *State) head() *cycle {
+	return &cycle{epoch: s.Epoch, revision: s.Revision, timestamp: s.UpdatedAt}
+}
+
+// setHead marks the state as brought up to the given cycle
+func (s *State) setHead(c *cycle) {
+	s.Epoch = c.epoch
+	s.Revision = c.revision
+	s.UpdatedAt = c.timestamp
 }
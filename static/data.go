@@ -1,5 +1,7 @@
 package static
 
+import "sort"
+
 func newData() *Data {
 	return &Data{
 		Countries:        make([]Country, 0),
@@ -13,6 +15,8 @@ func newData() *Data {
 		firIDIdx:         make(map[string]*FIR),
 		firPrefixIdx:     make(map[string]*FIR),
 		uirIDIdx:         make(map[string]*UIR),
+		firGrid:          newGrid(firGridStep),
+		airportGrid:      newGrid(airportGridStep),
 	}
 }
 
@@ -62,6 +66,54 @@ func (d *Data) FindFIR(id string) *FIR {
 	return d.firPrefixIdx[id]
 }
 
+// FindFIRsAt searches for FIRs whose boundaries contain the given point.
+// Every boundaries record of a FIR is checked including oceanic and extension
+// ones. FIRs are ordered from the most specific one by the matching record:
+// regular before oceanic, then by the size of the boundaries
+func (d *Data) FindFIRsAt(p Point) []*FIR {
+	type match struct {
+		fir        *FIR
+		boundaries *Boundaries
+	}
+
+	matches := make([]match, 0)
+	positions := make(map[int]int)
+	for _, idx := range d.firGrid.at(p) {
+		ref := d.firRefs[idx]
+		bnds := &d.FIRs[ref.fir].AllBoundaries[ref.boundaries]
+		if !bnds.Contains(p) {
+			continue
+		}
+		if pos, found := positions[ref.fir]; found {
+			// several records of the same FIR match, keep the best one
+			if moreSpecific(bnds, matches[pos].boundaries) {
+				matches[pos].boundaries = bnds
+			}
+			continue
+		}
+		positions[ref.fir] = len(matches)
+		matches = append(matches, match{fir: &d.FIRs[ref.fir], boundaries: bnds})
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return moreSpecific(matches[i].boundaries, matches[j].boundaries)
+	})
+
+	firs := make([]*FIR, len(matches))
+	for i := range matches {
+		firs[i] = matches[i].fir
+	}
+	return firs
+}
+
+// moreSpecific returns true if a is a better match for a point than b
+func moreSpecific(a *Boundaries, b *Boundaries) bool {
+	if a.IsOceanic != b.IsOceanic {
+		return !a.IsOceanic
+	}
+	return a.area() < b.area()
+}
+
 // FindUIR searches for a given UIR by its ID
 func (d *Data) FindUIR(id string) *UIR {
 	return d.uirIDIdx[id]
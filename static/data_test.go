@@ -0,0 +1,115 @@
+package static
+
+import (
+	"strings"
+	"testing"
+)
+
+const testData = `[Countries]
+United States|K|Center
+
+[Airports]
+PHNL|Honolulu|21.318|-157.922|HNL|KZAK|0
+
+[FIRs]
+KZAK|Oakland Oceanic|KZAK|KZAK
+PHZH|Honolulu|PHZH|PHZH
+
+[UIRs]
+`
+
+// KZAK has a regular record and an oceanic one crossing the antimeridian,
+// PHZH lies inside the oceanic one
+const testBoundaries = `KZAK|0|0|4|35|-130|40|-125|37|-127
+35|-130
+40|-130
+40|-125
+35|-125
+KZAK|1|0|4|-180|-180|180|180|20|-170
+0|170
+40|170
+40|-140
+0|-140
+PHZH|0|0|4|18|-162|24|-152|21|-157
+18|-162
+24|-162
+24|-152
+18|-152
+`
+
+func loadTestData(t *testing.T) *Data {
+	data, err := Read(strings.NewReader(testData), strings.NewReader(testBoundaries))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestFIRBoundariesRecords(t *testing.T) {
+	data := loadTestData(t)
+
+	fir := data.FindFIR("KZAK")
+	if len(fir.AllBoundaries) != 2 {
+		t.Fatalf("KZAK has %d boundaries records, want 2", len(fir.AllBoundaries))
+	}
+	if fir.Boundaries.IsOceanic {
+		t.Errorf("KZAK main boundaries record is the oceanic one")
+	}
+
+	oceanic := fir.AllBoundaries[1]
+	if !oceanic.CrossesAntimeridian {
+		t.Errorf("KZAK oceanic record is expected to cross the antimeridian")
+	}
+	if oceanic.Min.Lng != 170 || oceanic.Max.Lng != -140 {
+		t.Errorf("KZAK oceanic bounding box longitudes are %f..%f, want 170..-140", oceanic.Min.Lng, oceanic.Max.Lng)
+	}
+	if parts := oceanic.Split(); len(parts) != 2 {
+		t.Errorf("KZAK oceanic record is split into %d parts, want 2", len(parts))
+	}
+}
+
+func TestFindFIRsAt(t *testing.T) {
+	data := loadTestData(t)
+
+	tests := []struct {
+		name  string
+		point Point
+		want  []string
+	}{
+		{"regular record", Point{Lat: 37, Lng: -127}, []string{"KZAK"}},
+		{"oceanic record west of 180", Point{Lat: 30, Lng: 175}, []string{"KZAK"}},
+		{"oceanic record east of 180", Point{Lat: 30, Lng: -175}, []string{"KZAK"}},
+		{"regular FIR inside an oceanic one", Point{Lat: 21, Lng: -157}, []string{"PHZH", "KZAK"}},
+		{"outside", Point{Lat: 50, Lng: 0}, []string{}},
+		{"outside of the oceanic box", Point{Lat: 30, Lng: 160}, []string{}},
+	}
+
+	for _, tt := range tests {
+		firs := data.FindFIRsAt(tt.point)
+		ids := make([]string, len(firs))
+		for i, fir := range firs {
+			ids[i] = fir.ID
+		}
+		if strings.Join(ids, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s: FindFIRsAt(%v) = %v, want %v", tt.name, tt.point, ids, tt.want)
+		}
+	}
+}
+
+func TestFIRsGeoJSONFeatureIDs(t *testing.T) {
+	data := loadTestData(t)
+
+	fc := data.FIRsGeoJSON()
+	want := []string{"KZAK/0", "KZAK/1", "PHZH/0"}
+	if len(fc.Features) != len(want) {
+		t.Fatalf("got %d features, want %d", len(fc.Features), len(want))
+	}
+	for i, feature := range fc.Features {
+		if feature.ID != want[i] {
+			t.Errorf("feature %d id is %v, want %s", i, feature.ID, want[i])
+		}
+		if id := feature.Properties["id"]; id != want[i][:4] {
+			t.Errorf("feature %d FIR id is %v, want %s", i, id, want[i][:4])
+		}
+	}
+}
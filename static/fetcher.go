@@ -1,8 +1,13 @@
 package static
 
 import (
+	"context"
+	"errors"
+	"io"
 	"io/ioutil"
-	"net/http"
+	"sync"
+
+	"github.com/viert/go-vatspy/fetch"
 )
 
 // Load loads and parses data from a local file
@@ -18,27 +23,105 @@ func Load(dataFilename string, boundariesFilename string) (*Data, error) {
 	return parse(rawData, rawBoundaries)
 }
 
-// Fetch fetches and parses data from an HTTP url
-func Fetch(dataURL string, boundariesURL string) (*Data, error) {
-	resp, err := http.Get(dataURL)
+// Read reads and parses data from arbitrary readers
+func Read(dataReader io.Reader, boundariesReader io.Reader) (*Data, error) {
+	rawData, err := ioutil.ReadAll(dataReader)
 	if err != nil {
 		return nil, err
 	}
-	rawData, err := ioutil.ReadAll(resp.Body)
+	rawBoundaries, err := ioutil.ReadAll(boundariesReader)
 	if err != nil {
 		return nil, err
 	}
+	return parse(rawData, rawBoundaries)
+}
 
-	resp, err = http.Get(boundariesURL)
+// Fetch fetches and parses data from an HTTP url
+func Fetch(dataURL string, boundariesURL string) (*Data, error) {
+	return FetchContext(context.Background(), nil, dataURL, boundariesURL)
+}
+
+// FetchContext fetches and parses data from an HTTP url using the given client.
+// A nil client is replaced with the default one
+func FetchContext(ctx context.Context, client *fetch.Client, dataURL string, boundariesURL string) (*Data, error) {
+	rawData, err := client.Get(ctx, dataURL)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	rawBoundaries, err := ioutil.ReadAll(resp.Body)
+	rawBoundaries, err := client.Get(ctx, boundariesURL)
 	if err != nil {
 		return nil, err
 	}
 
 	return parse(rawData, rawBoundaries)
 }
+
+// Fetcher fetches static data over HTTP using conditional requests.
+// It remembers ETag/Last-Modified validators and the content checksum
+// of the last successful fetch and reports ErrNotModified when
+// nothing has changed since, skipping the parsing
+type Fetcher struct {
+	DataURL       string
+	BoundariesURL string
+
+	lock                 sync.Mutex
+	rawData              []byte
+	rawBoundaries        []byte
+	dataValidators       fetch.Validators
+	boundariesValidators fetch.Validators
+	checksum             string
+}
+
+// ErrNotModified is returned by Fetcher when static data hasn't changed
+var ErrNotModified = errors.New("static data has not changed")
+
+// NewFetcher creates a new Fetcher
+func NewFetcher(dataURL string, boundariesURL string) *Fetcher {
+	return &Fetcher{DataURL: dataURL, BoundariesURL: boundariesURL}
+}
+
+// Fetch fetches and parses data, returns ErrNotModified if the content
+// is the same as on the previous successful call
+func (f *Fetcher) Fetch(ctx context.Context, client *fetch.Client) (*Data, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	rawData, dataValidators, dataErr := client.GetConditional(ctx, f.DataURL, f.dataValidators)
+	if dataErr == fetch.ErrNotModified {
+		rawData = f.rawData
+	} else if dataErr != nil {
+		return nil, dataErr
+	}
+
+	rawBoundaries, boundariesValidators, bndErr := client.GetConditional(ctx, f.BoundariesURL, f.boundariesValidators)
+	if bndErr == fetch.ErrNotModified {
+		rawBoundaries = f.rawBoundaries
+	} else if bndErr != nil {
+		return nil, bndErr
+	}
+
+	if dataErr == fetch.ErrNotModified && bndErr == fetch.ErrNotModified {
+		return nil, ErrNotModified
+	}
+
+	sum := checksum(rawData, rawBoundaries)
+	if sum == f.checksum {
+		// servers without validators end up here
+		f.dataValidators = dataValidators
+		f.boundariesValidators = boundariesValidators
+		return nil, ErrNotModified
+	}
+
+	data, err := parse(rawData, rawBoundaries)
+	if err != nil {
+		return nil, err
+	}
+
+	f.rawData = rawData
+	f.rawBoundaries = rawBoundaries
+	f.dataValidators = dataValidators
+	f.boundariesValidators = boundariesValidators
+	f.checksum = sum
+	return data, nil
+}
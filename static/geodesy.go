@@ -0,0 +1,130 @@
+package static
+
+import "math"
+
+// Mean Earth radius used by the spherical geodesy methods
+const (
+	EarthRadiusNM = 3440.065
+	EarthRadiusKM = 6371.0
+)
+
+func toRadians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func toDegrees(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
+
+// normalizeLng wraps a longitude to [-180, 180) range
+func normalizeLng(lng float64) float64 {
+	lng = math.Mod(lng+180, 360)
+	if lng < 0 {
+		lng += 360
+	}
+	return lng - 180
+}
+
+// normalizeBearing wraps a bearing to [0, 360) range
+func normalizeBearing(brg float64) float64 {
+	brg = math.Mod(brg, 360)
+	if brg < 0 {
+		brg += 360
+	}
+	return brg
+}
+
+// Normalize returns the same location with latitude within [-90, 90]
+// and longitude within [-180, 180). Latitudes beyond a pole are folded
+// over it, i.e. 100,10 becomes 80,-170
+func (p Point) Normalize() Point {
+	lat := math.Mod(p.Lat, 360)
+	lng := p.Lng
+	if lat > 180 {
+		lat -= 360
+	} else if lat < -180 {
+		lat += 360
+	}
+	if lat > 90 {
+		lat = 180 - lat
+		lng += 180
+	} else if lat < -90 {
+		lat = -180 - lat
+		lng += 180
+	}
+	return Point{Lat: lat, Lng: normalizeLng(lng)}
+}
+
+// angularDistance returns the central angle between two points in radians
+func (p Point) angularDistance(other Point) float64 {
+	lat1, lat2 := toRadians(p.Lat), toRadians(other.Lat)
+	dLat := lat2 - lat1
+	dLng := toRadians(other.Lng - p.Lng)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// DistanceNM returns the great-circle distance to another point in nautical miles
+func (p Point) DistanceNM(other Point) float64 {
+	return p.angularDistance(other) * EarthRadiusNM
+}
+
+// DistanceKM returns the great-circle distance to another point in kilometers
+func (p Point) DistanceKM(other Point) float64 {
+	return p.angularDistance(other) * EarthRadiusKM
+}
+
+// InitialBearing returns the true course in degrees [0, 360)
+// to follow from the point to reach the other one along the great circle
+func (p Point) InitialBearing(other Point) float64 {
+	lat1, lat2 := toRadians(p.Lat), toRadians(other.Lat)
+	dLng := toRadians(other.Lng - p.Lng)
+	y := math.Sin(dLng) * math.Cos(lat2)
+	x := math.Cos(lat1)*math.Sin(lat2) - math.Sin(lat1)*math.Cos(lat2)*math.Cos(dLng)
+	return normalizeBearing(toDegrees(math.Atan2(y, x)))
+}
+
+// FinalBearing returns the true course in degrees [0, 360)
+// on arrival to the other point along the great circle
+func (p Point) FinalBearing(other Point) float64 {
+	return normalizeBearing(other.InitialBearing(p) + 180)
+}
+
+// Destination returns the point reached by travelling the given distance
+// in nautical miles along the great circle starting with the given bearing
+func (p Point) Destination(bearing float64, distanceNM float64) Point {
+	lat1, lng1 := toRadians(p.Lat), toRadians(p.Lng)
+	brg := toRadians(bearing)
+	d := distanceNM / EarthRadiusNM
+
+	lat2 := math.Asin(math.Sin(lat1)*math.Cos(d) + math.Cos(lat1)*math.Sin(d)*math.Cos(brg))
+	lng2 := lng1 + math.Atan2(
+		math.Sin(brg)*math.Sin(d)*math.Cos(lat1),
+		math.Cos(d)-math.Sin(lat1)*math.Sin(lat2),
+	)
+	return Point{Lat: toDegrees(lat2), Lng: normalizeLng(toDegrees(lng2))}
+}
+
+// Midpoint returns the point halfway to the other one along the great circle
+func (p Point) Midpoint(other Point) Point {
+	lat1, lng1 := toRadians(p.Lat), toRadians(p.Lng)
+	lat2 := toRadians(other.Lat)
+	dLng := toRadians(other.Lng - p.Lng)
+
+	bx := math.Cos(lat2) * math.Cos(dLng)
+	by := math.Cos(lat2) * math.Sin(dLng)
+	lat := math.Atan2(math.Sin(lat1)+math.Sin(lat2), math.Sqrt((math.Cos(lat1)+bx)*(math.Cos(lat1)+bx)+by*by))
+	lng := lng1 + math.Atan2(by, math.Cos(lat1)+bx)
+	return Point{Lat: toDegrees(lat), Lng: normalizeLng(toDegrees(lng))}
+}
+
+// CrossTrackDistanceNM returns the distance in nautical miles from the point
+// to the great circle path going from start to end. The distance is positive
+// if the point is to the right of the path and negative if to the left
+func (p Point) CrossTrackDistanceNM(start Point, end Point) float64 {
+	d13 := start.angularDistance(p)
+	brg13 := toRadians(start.InitialBearing(p))
+	brg12 := toRadians(start.InitialBearing(end))
+	return math.Asin(math.Sin(d13)*math.Sin(brg13-brg12)) * EarthRadiusNM
+}
@@ -0,0 +1,164 @@
+package static
+
+import (
+	"math"
+	"testing"
+)
+
+var (
+	egll = Point{Lat: 51.4775, Lng: -0.461389}
+	kjfk = Point{Lat: 40.6398, Lng: -73.7789}
+)
+
+func near(a float64, b float64, tolerance float64) bool {
+	return math.Abs(a-b) <= tolerance
+}
+
+// lngNear compares longitudes (or bearings) modulo 360 degrees,
+// i.e. -180 and 180 are the same meridian
+func lngNear(a float64, b float64, tolerance float64) bool {
+	return math.Abs(normalizeLng(a-b)) <= tolerance
+}
+
+func pointNear(a Point, b Point, tolerance float64) bool {
+	return near(a.Lat, b.Lat, tolerance) && lngNear(a.Lng, b.Lng, tolerance)
+}
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		name string
+		from Point
+		to   Point
+		nm   float64
+		km   float64
+	}{
+		{"EGLL-KJFK", egll, kjfk, 2991.1, 5539.4},
+		{"same point", egll, egll, 0, 0},
+		{"one degree of latitude", Point{Lat: 0, Lng: 0}, Point{Lat: 1, Lng: 0}, 60.04, 111.19},
+		{"across the antimeridian", Point{Lat: 0, Lng: 179.5}, Point{Lat: 0, Lng: -179.5}, 60.04, 111.19},
+	}
+
+	for _, tt := range tests {
+		if nm := tt.from.DistanceNM(tt.to); !near(nm, tt.nm, 0.5) {
+			t.Errorf("%s: DistanceNM = %f, want %f", tt.name, nm, tt.nm)
+		}
+		if km := tt.from.DistanceKM(tt.to); !near(km, tt.km, 1) {
+			t.Errorf("%s: DistanceKM = %f, want %f", tt.name, km, tt.km)
+		}
+	}
+}
+
+func TestBearing(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    Point
+		to      Point
+		initial float64
+		final   float64
+	}{
+		{"EGLL-KJFK", egll, kjfk, 287.93, 231.34},
+		{"KJFK-EGLL", kjfk, egll, 51.34, 107.93},
+		{"due north", Point{Lat: 0, Lng: 0}, Point{Lat: 10, Lng: 0}, 0, 0},
+		{"due east across the antimeridian", Point{Lat: 0, Lng: 179}, Point{Lat: 0, Lng: -179}, 90, 90},
+	}
+
+	for _, tt := range tests {
+		if brg := tt.from.InitialBearing(tt.to); !lngNear(brg, tt.initial, 0.05) {
+			t.Errorf("%s: InitialBearing = %f, want %f", tt.name, brg, tt.initial)
+		}
+		if brg := tt.from.FinalBearing(tt.to); !lngNear(brg, tt.final, 0.05) {
+			t.Errorf("%s: FinalBearing = %f, want %f", tt.name, brg, tt.final)
+		}
+	}
+}
+
+func TestDestination(t *testing.T) {
+	tests := []struct {
+		name     string
+		from     Point
+		bearing  float64
+		distance float64
+		want     Point
+	}{
+		{"east across the antimeridian", Point{Lat: 0, Lng: 179}, 90, 120.08, Point{Lat: 0, Lng: -179}},
+		{"west across the antimeridian", Point{Lat: 0, Lng: -179}, 270, 120.08, Point{Lat: 0, Lng: 179}},
+		{"north", Point{Lat: 0, Lng: 0}, 0, 60.04, Point{Lat: 1, Lng: 0}},
+		{"EGLL to KJFK", egll, 287.93, 2991.06, kjfk},
+	}
+
+	for _, tt := range tests {
+		if got := tt.from.Destination(tt.bearing, tt.distance); !pointNear(got, tt.want, 0.01) {
+			t.Errorf("%s: Destination = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMidpoint(t *testing.T) {
+	tests := []struct {
+		name string
+		a    Point
+		b    Point
+		want Point
+	}{
+		{"across the antimeridian", Point{Lat: 10, Lng: 179}, Point{Lat: 10, Lng: -179}, Point{Lat: 10.0015, Lng: 180}},
+		{"on the equator", Point{Lat: 0, Lng: 0}, Point{Lat: 0, Lng: 10}, Point{Lat: 0, Lng: 5}},
+		{"EGLL-KJFK", egll, kjfk, Point{Lat: 52.2183, Lng: -41.3101}},
+	}
+
+	for _, tt := range tests {
+		if got := tt.a.Midpoint(tt.b); !pointNear(got, tt.want, 0.001) {
+			t.Errorf("%s: Midpoint = %v, want %v", tt.name, got, tt.want)
+		}
+		if got := tt.a.Midpoint(tt.b); got.Lng < -180 || got.Lng >= 180 {
+			t.Errorf("%s: Midpoint longitude %f is out of range", tt.name, got.Lng)
+		}
+	}
+}
+
+func TestCrossTrackDistance(t *testing.T) {
+	south := Point{Lat: -1, Lng: 0}
+	north := Point{Lat: 1, Lng: 0}
+
+	tests := []struct {
+		name  string
+		point Point
+		start Point
+		end   Point
+		want  float64
+	}{
+		{"right of the track", Point{Lat: 0, Lng: 1}, south, north, 60.04},
+		{"left of the track", Point{Lat: 0, Lng: -1}, south, north, -60.04},
+		{"on the track", Point{Lat: 0, Lng: 0}, south, north, 0},
+		{"left of the reversed track", Point{Lat: 0, Lng: 1}, north, south, -60.04},
+		{"right of a track across the antimeridian", Point{Lat: -1, Lng: 180}, Point{Lat: 0, Lng: 179}, Point{Lat: 0, Lng: -179}, 60.04},
+	}
+
+	for _, tt := range tests {
+		if got := tt.point.CrossTrackDistanceNM(tt.start, tt.end); !near(got, tt.want, 0.05) {
+			t.Errorf("%s: CrossTrackDistanceNM = %f, want %f", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name  string
+		point Point
+		want  Point
+	}{
+		{"over the north pole", Point{Lat: 100, Lng: 10}, Point{Lat: 80, Lng: -170}},
+		{"over the south pole", Point{Lat: -95, Lng: -170}, Point{Lat: -85, Lng: 10}},
+		{"east of 180", Point{Lat: 0, Lng: 190}, Point{Lat: 0, Lng: -170}},
+		{"west of -180", Point{Lat: 0, Lng: -181}, Point{Lat: 0, Lng: 179}},
+		{"exactly 180", Point{Lat: 10, Lng: 180}, Point{Lat: 10, Lng: -180}},
+		{"several turns", Point{Lat: 0, Lng: 730}, Point{Lat: 0, Lng: 10}},
+		{"already normal", Point{Lat: 51.5, Lng: -0.5}, Point{Lat: 51.5, Lng: -0.5}},
+	}
+
+	for _, tt := range tests {
+		got := tt.point.Normalize()
+		if !near(got.Lat, tt.want.Lat, 1e-9) || !near(got.Lng, tt.want.Lng, 1e-9) {
+			t.Errorf("%s: Normalize = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
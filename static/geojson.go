@@ -0,0 +1,101 @@
+package static
+
+import (
+	"fmt"
+
+	"github.com/viert/go-vatspy/geojson"
+)
+
+// Position returns the point as a GeoJSON position
+func (p Point) Position() geojson.Position {
+	return geojson.NewPosition(p.Lat, p.Lng)
+}
+
+func positions(points []Point) []geojson.Position {
+	pos := make([]geojson.Position, len(points))
+	for i, p := range points {
+		pos[i] = p.Position()
+	}
+	return pos
+}
+
+// Geometry returns the boundaries as a GeoJSON Polygon, or a MultiPolygon
+// split at the antimeridian if the boundaries cross it. Returns nil
+// if there are no boundaries
+func (b *Boundaries) Geometry() *geojson.Geometry {
+	if len(b.Points) < 3 {
+		return nil
+	}
+	parts := b.Split()
+	if len(parts) == 1 {
+		return geojson.NewPolygon(positions(parts[0]))
+	}
+	rings := make([][]geojson.Position, len(parts))
+	for i, part := range parts {
+		rings[i] = positions(part)
+	}
+	return geojson.NewMultiPolygon(rings...)
+}
+
+// FeatureID returns a unique GeoJSON feature id of the FIR's boundaries record
+// with the given index in AllBoundaries, i.e. "KZAK/1"
+func (f *FIR) FeatureID(record int) string {
+	return fmt.Sprintf("%s/%d", f.ID, record)
+}
+
+// Properties returns the FIR's GeoJSON feature properties
+// with the oceanic and extension flags of the given boundaries record
+func (f *FIR) Properties(b *Boundaries) geojson.Properties {
+	return geojson.Properties{
+		"id":           f.ID,
+		"name":         f.Name,
+		"prefix":       f.Prefix,
+		"parent_id":    f.ParentID,
+		"is_oceanic":   b.IsOceanic,
+		"is_extension": b.IsExtension,
+	}
+}
+
+// Properties returns the airport's GeoJSON feature properties
+func (a *Airport) Properties() geojson.Properties {
+	return geojson.Properties{
+		"icao":      a.ICAO,
+		"name":      a.Name,
+		"iata":      a.IATA,
+		"fir_id":    a.FIRID,
+		"is_pseudo": a.IsPseudo,
+	}
+}
+
+// FIRsGeoJSON returns FIR boundaries as a GeoJSON FeatureCollection
+// with a feature per boundaries record identified by FIR.FeatureID,
+// the FIR ID is kept in the "id" property. FIRs without boundaries are skipped
+func (d *Data) FIRsGeoJSON() *geojson.FeatureCollection {
+	fc := geojson.NewFeatureCollection()
+	for i := range d.FIRs {
+		fir := &d.FIRs[i]
+		for j := range fir.AllBoundaries {
+			bnds := &fir.AllBoundaries[j]
+			geometry := bnds.Geometry()
+			if geometry == nil {
+				continue
+			}
+			fc.Add(geojson.NewFeature(fir.FeatureID(j), geometry, fir.Properties(bnds)))
+		}
+	}
+	return fc
+}
+
+// AirportsGeoJSON returns airports as a GeoJSON FeatureCollection of points.
+// Pseudo airports are skipped if excludePseudo is set
+func (d *Data) AirportsGeoJSON(excludePseudo bool) *geojson.FeatureCollection {
+	fc := geojson.NewFeatureCollection()
+	for i := range d.Airports {
+		airport := &d.Airports[i]
+		if excludePseudo && airport.IsPseudo {
+			continue
+		}
+		fc.Add(geojson.NewFeature(airport.ICAO, geojson.NewPoint(airport.Position.Position()), airport.Properties()))
+	}
+	return fc
+}
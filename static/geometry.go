@@ -0,0 +1,250 @@
+package static
+
+import "math"
+
+// InBox returns true if the point is located within the given bounding box.
+// A box with Min.Lng greater than Max.Lng spans the antimeridian
+func (p Point) InBox(min Point, max Point) bool {
+	if p.Lat < min.Lat || p.Lat > max.Lat {
+		return false
+	}
+	if min.Lng > max.Lng {
+		return p.Lng >= min.Lng || p.Lng <= max.Lng
+	}
+	return p.Lng >= min.Lng && p.Lng <= max.Lng
+}
+
+// Contains returns true if the point is located inside the boundaries polygon
+func (b *Boundaries) Contains(p Point) bool {
+	if len(b.Points) < 3 || !p.InBox(b.Min, b.Max) {
+		return false
+	}
+	if !b.CrossesAntimeridian {
+		return polygonContains(b.Points, p)
+	}
+
+	// normalized longitudes may go beyond ±180
+	points := b.Normalized()
+	for _, shift := range []float64{0, 360, -360} {
+		if polygonContains(points, Point{Lat: p.Lat, Lng: p.Lng + shift}) {
+			return true
+		}
+	}
+	return false
+}
+
+// setBoundaries assigns all the boundaries records to the FIR
+// picking the first non-extension one as the main record
+func (f *FIR) setBoundaries(records []Boundaries) {
+	f.AllBoundaries = records
+	f.Boundaries = records[0]
+	for _, bnds := range records {
+		if !bnds.IsExtension {
+			f.Boundaries = bnds
+			break
+		}
+	}
+}
+
+// Contains returns true if any of the FIR's boundaries records contains the point
+func (f *FIR) Contains(p Point) bool {
+	for i := range f.AllBoundaries {
+		if f.AllBoundaries[i].Contains(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// OverlapsBox returns true if the bounding box of any of the FIR's
+// boundaries records overlaps the given one
+func (f *FIR) OverlapsBox(min Point, max Point) bool {
+	for i := range f.AllBoundaries {
+		if f.AllBoundaries[i].OverlapsBox(min, max) {
+			return true
+		}
+	}
+	return false
+}
+
+// OverlapsBox returns true if the boundaries' bounding box overlaps the given one.
+// Both boxes may span the antimeridian
+func (b *Boundaries) OverlapsBox(min Point, max Point) bool {
+	if b.Min.Lat > max.Lat || b.Max.Lat < min.Lat {
+		return false
+	}
+	for _, a := range lngIntervals(b.Min.Lng, b.Max.Lng) {
+		for _, o := range lngIntervals(min.Lng, max.Lng) {
+			if a[0] <= o[1] && a[1] >= o[0] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Normalized returns the polygon points with longitudes unwrapped so that
+// the polygon is continuous, i.e. a polygon crossing the antimeridian
+// gets longitudes like 170, 180, 190 instead of 170, 180, -170
+func (b *Boundaries) Normalized() []Point {
+	if !b.CrossesAntimeridian {
+		return b.Points
+	}
+	if b.unwrapped == nil {
+		return unwrapLng(b.Points)
+	}
+	return b.unwrapped
+}
+
+// Split returns the polygon as one or more parts each of them lying within
+// [-180, 180] longitude range. Polygons crossing the antimeridian are cut
+// into the eastern and the western parts
+func (b *Boundaries) Split() [][]Point {
+	if !b.CrossesAntimeridian {
+		return [][]Point{b.Points}
+	}
+
+	points := b.Normalized()
+	minLng, maxLng := points[0].Lng, points[0].Lng
+	for _, pt := range points {
+		minLng = math.Min(minLng, pt.Lng)
+		maxLng = math.Max(maxLng, pt.Lng)
+	}
+
+	parts := make([][]Point, 0, 2)
+	for shift := -360.0; shift <= 360; shift += 360 {
+		lo, hi := shift-180, shift+180
+		if maxLng <= lo || minLng >= hi {
+			continue
+		}
+		part := clipLng(clipLng(points, lo, true), hi, false)
+		if len(part) < 3 {
+			continue
+		}
+		for i := range part {
+			part[i].Lng -= shift
+		}
+		parts = append(parts, part)
+	}
+	return parts
+}
+
+// detectAntimeridian checks if the polygon crosses the antimeridian
+// and fixes the bounding box if it does
+func (b *Boundaries) detectAntimeridian() {
+	crosses := false
+	for i := 1; i < len(b.Points); i++ {
+		if math.Abs(b.Points[i].Lng-b.Points[i-1].Lng) > 180 {
+			crosses = true
+			break
+		}
+	}
+	if !crosses {
+		return
+	}
+
+	unwrapped := unwrapLng(b.Points)
+	first, last := unwrapped[0], unwrapped[len(unwrapped)-1]
+	if math.Abs(last.Lng-first.Lng) > 180 {
+		// the polygon encircles a pole rather than crossing the antimeridian
+		return
+	}
+
+	b.CrossesAntimeridian = true
+	b.unwrapped = unwrapped
+
+	min, max := unwrapped[0], unwrapped[0]
+	for _, pt := range unwrapped {
+		min.Lat = math.Min(min.Lat, pt.Lat)
+		min.Lng = math.Min(min.Lng, pt.Lng)
+		max.Lat = math.Max(max.Lat, pt.Lat)
+		max.Lng = math.Max(max.Lng, pt.Lng)
+	}
+	min.Lng = normalizeLng(min.Lng)
+	max.Lng = normalizeLng(max.Lng)
+	b.Min, b.Max = min, max
+}
+
+// area returns the area of the bounding box in square degrees
+func (b *Boundaries) area() float64 {
+	width := b.Max.Lng - b.Min.Lng
+	if width < 0 {
+		width += 360
+	}
+	return (b.Max.Lat - b.Min.Lat) * width
+}
+
+// lngIntervals splits a longitude range spanning the antimeridian in two
+func lngIntervals(min float64, max float64) [][2]float64 {
+	if min > max {
+		return [][2]float64{{min, 180}, {-180, max}}
+	}
+	return [][2]float64{{min, max}}
+}
+
+// unwrapLng shifts longitudes by 360 degrees where needed
+// to avoid jumps between consecutive points
+func unwrapLng(points []Point) []Point {
+	unwrapped := make([]Point, len(points))
+	if len(points) == 0 {
+		return unwrapped
+	}
+	unwrapped[0] = points[0]
+	for i := 1; i < len(points); i++ {
+		pt := points[i]
+		prev := unwrapped[i-1].Lng
+		for pt.Lng-prev > 180 {
+			pt.Lng -= 360
+		}
+		for prev-pt.Lng > 180 {
+			pt.Lng += 360
+		}
+		unwrapped[i] = pt
+	}
+	return unwrapped
+}
+
+// clipLng clips a polygon by a meridian keeping the part east of it
+// if keepEast is set or the western part otherwise (Sutherland-Hodgman)
+func clipLng(points []Point, lng float64, keepEast bool) []Point {
+	inside := func(p Point) bool {
+		if keepEast {
+			return p.Lng >= lng
+		}
+		return p.Lng <= lng
+	}
+	intersect := func(a Point, b Point) Point {
+		t := (lng - a.Lng) / (b.Lng - a.Lng)
+		return Point{Lat: a.Lat + t*(b.Lat-a.Lat), Lng: lng}
+	}
+
+	clipped := make([]Point, 0, len(points)+2)
+	for i := range points {
+		cur := points[i]
+		prev := points[(i+len(points)-1)%len(points)]
+		if inside(cur) {
+			if !inside(prev) {
+				clipped = append(clipped, intersect(prev, cur))
+			}
+			clipped = append(clipped, cur)
+		} else if inside(prev) {
+			clipped = append(clipped, intersect(prev, cur))
+		}
+	}
+	return clipped
+}
+
+// polygonContains is a ray casting point-in-polygon test
+func polygonContains(points []Point, p Point) bool {
+	inside := false
+	j := len(points) - 1
+	for i := range points {
+		a, b := points[i], points[j]
+		if (a.Lat > p.Lat) != (b.Lat > p.Lat) &&
+			p.Lng < (b.Lng-a.Lng)*(p.Lat-a.Lat)/(b.Lat-a.Lat)+a.Lng {
+			inside = !inside
+		}
+		j = i
+	}
+	return inside
+}
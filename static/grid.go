@@ -0,0 +1,88 @@
+package static
+
+import "math"
+
+// grid cell sizes in degrees
+const (
+	firGridStep     = 5.0
+	airportGridStep = 1.0
+)
+
+type (
+	gridCell struct {
+		lat int
+		lng int
+	}
+
+	// grid is a simple spatial index mapping fixed size lat/lng cells
+	// to the indexes of objects overlapping them
+	grid struct {
+		step  float64
+		cells map[gridCell][]int
+	}
+)
+
+func newGrid(step float64) *grid {
+	return &grid{step: step, cells: make(map[gridCell][]int)}
+}
+
+func (g *grid) cellOf(p Point) gridCell {
+	return gridCell{
+		lat: int(math.Floor(p.Lat / g.step)),
+		lng: int(math.Floor(p.Lng / g.step)),
+	}
+}
+
+// insert adds an object to all the cells overlapping its bounding box,
+// the box may span the antimeridian
+func (g *grid) insert(idx int, min Point, max Point) {
+	from := g.cellOf(min)
+	to := g.cellOf(max)
+	if min.Lng > max.Lng {
+		to.lng += int(360 / g.step)
+	}
+	for lat := from.lat; lat <= to.lat; lat++ {
+		for lng := from.lng; lng <= to.lng; lng++ {
+			cell := g.wrap(gridCell{lat, lng})
+			g.cells[cell] = append(g.cells[cell], idx)
+		}
+	}
+}
+
+// at returns the indexes of objects which may contain the given point
+func (g *grid) at(p Point) []int {
+	return g.cells[g.wrap(g.cellOf(p))]
+}
+
+// wrap normalizes the cell longitude to [-180, 180) range
+func (g *grid) wrap(cell gridCell) gridCell {
+	n := int(360 / g.step)
+	half := n / 2
+	cell.lng = ((cell.lng+half)%n+n)%n - half
+	return cell
+}
+
+// ring returns the cells at the given distance (in cells) from the center
+// forming the border of a square, a zero radius means the center itself.
+// Cells wrap around the antimeridian, cells beyond the poles are skipped
+func (g *grid) ring(center gridCell, radius int) []gridCell {
+	if radius == 0 {
+		return []gridCell{g.wrap(center)}
+	}
+	cells := make([]gridCell, 0, 8*radius)
+	add := func(lat int, lng int) {
+		if float64(lat)*g.step >= 90 || float64(lat+1)*g.step <= -90 {
+			return
+		}
+		cells = append(cells, g.wrap(gridCell{lat, lng}))
+	}
+	for lng := center.lng - radius; lng <= center.lng+radius; lng++ {
+		add(center.lat-radius, lng)
+		add(center.lat+radius, lng)
+	}
+	for lat := center.lat - radius + 1; lat < center.lat+radius; lat++ {
+		add(lat, center.lng-radius)
+		add(lat, center.lng+radius)
+	}
+	return cells
+}
@@ -0,0 +1,118 @@
+package static
+
+import (
+	"math"
+	"sort"
+)
+
+type airportDistance struct {
+	airport  *Airport
+	distance float64
+}
+
+func sortByDistance(found []airportDistance) {
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].distance < found[j].distance
+	})
+}
+
+func airportsOf(found []airportDistance) []*Airport {
+	airports := make([]*Airport, len(found))
+	for i := range found {
+		airports[i] = found[i].airport
+	}
+	return airports
+}
+
+// ringDistanceNM returns the lower bound of the distance from a point
+// to anything outside the ring of cells with the given radius around it
+func (g *grid) ringDistanceNM(p Point, radius int) float64 {
+	deg := float64(radius) * g.step
+	latBound := toRadians(deg) * EarthRadiusNM
+	lngBound := math.Asin(math.Cos(toRadians(p.Lat))*math.Sin(toRadians(math.Min(deg, 90)))) * EarthRadiusNM
+	return math.Min(latBound, lngBound)
+}
+
+// NearestAirports returns up to k airports closest to the given point,
+// nearest first. Pseudo airports are skipped if excludePseudo is set
+func (d *Data) NearestAirports(p Point, k int, excludePseudo bool) []*Airport {
+	found := make([]airportDistance, 0)
+	if k <= 0 {
+		return airportsOf(found)
+	}
+
+	g := d.airportGrid
+	center := g.cellOf(p)
+	// rings wider than that cover the whole globe
+	maxRadius := int(180/g.step) + 1
+	visited := make(map[gridCell]bool)
+
+	for radius := 0; radius <= maxRadius; radius++ {
+		for _, cell := range g.ring(center, radius) {
+			if visited[cell] {
+				continue
+			}
+			visited[cell] = true
+			for _, idx := range g.cells[cell] {
+				airport := &d.Airports[idx]
+				if excludePseudo && airport.IsPseudo {
+					continue
+				}
+				found = append(found, airportDistance{airport, p.DistanceNM(airport.Position)})
+			}
+		}
+
+		if len(found) >= k {
+			sortByDistance(found)
+			if found[k-1].distance <= g.ringDistanceNM(p, radius) {
+				break
+			}
+		}
+	}
+
+	sortByDistance(found)
+	if len(found) > k {
+		found = found[:k]
+	}
+	return airportsOf(found)
+}
+
+// AirportsWithin returns airports located within the given radius
+// in nautical miles from the point, nearest first.
+// Pseudo airports are skipped if excludePseudo is set
+func (d *Data) AirportsWithin(p Point, radiusNM float64, excludePseudo bool) []*Airport {
+	found := make([]airportDistance, 0)
+	if radiusNM < 0 {
+		return airportsOf(found)
+	}
+
+	g := d.airportGrid
+	center := g.cellOf(p)
+	maxRadius := int(180/g.step) + 1
+	visited := make(map[gridCell]bool)
+
+	for radius := 0; radius <= maxRadius; radius++ {
+		if radius > 0 && g.ringDistanceNM(p, radius-1) > radiusNM {
+			break
+		}
+		for _, cell := range g.ring(center, radius) {
+			if visited[cell] {
+				continue
+			}
+			visited[cell] = true
+			for _, idx := range g.cells[cell] {
+				airport := &d.Airports[idx]
+				if excludePseudo && airport.IsPseudo {
+					continue
+				}
+				distance := p.DistanceNM(airport.Position)
+				if distance <= radiusNM {
+					found = append(found, airportDistance{airport, distance})
+				}
+			}
+		}
+	}
+
+	sortByDistance(found)
+	return airportsOf(found)
+}
@@ -3,6 +3,8 @@ package static
 import (
 	"bufio"
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"strconv"
 	"strings"
@@ -32,11 +34,19 @@ func parse(dataRaw []byte, boundariesRaw []byte) (*Data, error) {
 	}
 
 	makeIndexes(data)
+	data.Checksum = checksum(dataRaw, boundariesRaw)
 
 	return data, nil
 }
 
-func parseData(data []byte, boundaries map[string]Boundaries) (*Data, error) {
+func checksum(dataRaw []byte, boundariesRaw []byte) string {
+	h := sha256.New()
+	h.Write(dataRaw)
+	h.Write(boundariesRaw)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func parseData(data []byte, boundaries map[string][]Boundaries) (*Data, error) {
 	results := newData()
 
 	state := stateReadCategory
@@ -114,11 +124,11 @@ func parseData(data []byte, boundaries map[string]Boundaries) (*Data, error) {
 			}
 
 			if bnds, found := boundaries[fir.ID]; found {
-				fir.Boundaries = bnds
+				fir.setBoundaries(bnds)
 			} else if bnds, found := boundaries[fir.Prefix]; found {
-				fir.Boundaries = bnds
+				fir.setBoundaries(bnds)
 			} else if bnds, found := boundaries[fir.ParentID]; found {
-				fir.Boundaries = bnds
+				fir.setBoundaries(bnds)
 			}
 
 			results.FIRs = append(results.FIRs, fir)
@@ -143,13 +153,14 @@ func parseData(data []byte, boundaries map[string]Boundaries) (*Data, error) {
 	return results, nil
 }
 
-func parseBoundaries(data []byte) (map[string]Boundaries, error) {
+func parseBoundaries(data []byte) (map[string][]Boundaries, error) {
 
 	var points []Point
 	var icao string
 	var current Boundaries
 
-	boundaries := make(map[string]Boundaries)
+	// a FIR may have several records, i.e. oceanic and extension ones
+	boundaries := make(map[string][]Boundaries)
 
 	sc := bufio.NewScanner(bytes.NewReader(data))
 	pointsLeft := 0
@@ -231,7 +242,8 @@ func parseBoundaries(data []byte) (map[string]Boundaries, error) {
 
 			if pointsLeft == 0 {
 				current.Points = points
-				boundaries[icao] = current
+				current.detectAntimeridian()
+				boundaries[icao] = append(boundaries[icao], current)
 			}
 		}
 	}
@@ -256,12 +268,20 @@ func makeIndexes(data *Data) {
 		airport := &data.Airports[i]
 		data.airportIATAIdx[airport.IATA] = airport
 		data.airportICAOIdx[airport.ICAO] = airport
+		data.airportGrid.insert(i, airport.Position, airport.Position)
 	}
 
 	for i := range data.FIRs {
 		fir := &data.FIRs[i]
 		data.firIDIdx[fir.ID] = fir
 		data.firPrefixIdx[fir.Prefix] = fir
+		for j := range fir.AllBoundaries {
+			bnds := &fir.AllBoundaries[j]
+			if len(bnds.Points) > 0 {
+				data.firGrid.insert(len(data.firRefs), bnds.Min, bnds.Max)
+				data.firRefs = append(data.firRefs, firBoundariesRef{fir: i, boundaries: j})
+			}
+		}
 	}
 
 	for i := range data.UIRs {
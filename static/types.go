@@ -7,14 +7,18 @@ type (
 		Lng float64 `json:"lng"`
 	}
 
-	// Boundaries object
+	// Boundaries object.
+	// For polygons crossing the antimeridian Min.Lng is the western edge
+	// and Max.Lng is the eastern one, so Min.Lng is greater than Max.Lng
 	Boundaries struct {
-		IsOceanic   bool    `json:"is_oceanic"`
-		IsExtension bool    `json:"is_extension"`
-		Min         Point   `json:"min"`
-		Max         Point   `json:"max"`
-		Center      Point   `json:"center"`
-		Points      []Point `json:"points"`
+		IsOceanic           bool    `json:"is_oceanic"`
+		IsExtension         bool    `json:"is_extension"`
+		CrossesAntimeridian bool    `json:"crosses_antimeridian"`
+		Min                 Point   `json:"min"`
+		Max                 Point   `json:"max"`
+		Center              Point   `json:"center"`
+		Points              []Point `json:"points"`
+		unwrapped           []Point
 	}
 
 	// Country object
@@ -36,11 +40,22 @@ type (
 
 	// FIR object
 	FIR struct {
-		ID         string     `json:"id"`
-		Name       string     `json:"name"`
-		Prefix     string     `json:"prefix"`
-		ParentID   string     `json:"parent_id"`
+		ID       string `json:"id"`
+		Name     string `json:"name"`
+		Prefix   string `json:"prefix"`
+		ParentID string `json:"parent_id"`
+		// Boundaries is the main boundaries record, the first one
+		// which is not an extension
 		Boundaries Boundaries `json:"boundaries"`
+		// AllBoundaries lists every boundaries record of the FIR
+		// including oceanic and extension ones
+		AllBoundaries []Boundaries `json:"all_boundaries"`
+	}
+
+	// firBoundariesRef points to a single boundaries record of a FIR
+	firBoundariesRef struct {
+		fir        int
+		boundaries int
 	}
 
 	// UIR object
@@ -57,6 +72,7 @@ type (
 		Airports         []Airport `json:"airports"`
 		FIRs             []FIR     `json:"firs"`
 		UIRs             []UIR     `json:"uirs"`
+		Checksum         string    `json:"checksum"`
 		countryNameIdx   map[string][]*Country
 		countryPrefixIdx map[string]*Country
 		airportICAOIdx   map[string]*Airport
@@ -64,5 +80,8 @@ type (
 		firIDIdx         map[string]*FIR
 		firPrefixIdx     map[string]*FIR
 		uirIDIdx         map[string]*UIR
+		firGrid          *grid
+		firRefs          []firBoundariesRef
+		airportGrid      *grid
 	}
 )
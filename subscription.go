@@ -1,12 +1,10 @@
 package vatspy
 
 import (
-	"fmt"
-	"strings"
+	"sync"
+	"time"
 
 	"github.com/op/go-logging"
-	"github.com/viert/go-vatspy/dynamic"
-	"github.com/viert/go-vatspy/static"
 )
 
 // UpdateType is a update type enum
@@ -17,6 +15,15 @@ const (
 	ObjectAdd UpdateType = iota
 	ObjectModify
 	ObjectRemove
+	// StateReset tells the consumer to discard all the objects received so far,
+	// ObjectAdd updates for the current state follow. Object is nil,
+	// Revision is the revision of the state being sent
+	StateReset
+	// CycleStart and CycleEnd enclose the updates of a single fetch cycle,
+	// a snapshot or a replayed cycle. Object is nil. Only sent to
+	// subscriptions with CycleMarkers set
+	CycleStart
+	CycleEnd
 )
 
 var (
@@ -24,6 +31,9 @@ var (
 		ObjectAdd:    "add",
 		ObjectModify: "modify",
 		ObjectRemove: "remove",
+		StateReset:   "reset",
+		CycleStart:   "cycle-start",
+		CycleEnd:     "cycle-end",
 	}
 	log = logging.MustGetLogger("vatspy")
 )
@@ -32,365 +42,223 @@ func (ut UpdateType) String() string {
 	return updateTypeNames[ut]
 }
 
-// Update is an update of a state object
+// Update is an update of a state object.
+// Use Kind to find out the object type and typed accessors
+// (Airport, Radar, Country, Pilot) to get the object itself
 type Update struct {
 	Type   UpdateType
+	Kind   ObjectKind
 	Object interface{}
+	// Revision is the provider's fetch cycle the update belongs to.
+	// Revisions increase monotonically within a Provider instance
+	Revision uint64
+	// Epoch identifies the Provider instance the revision belongs to
+	Epoch string
+	// Timestamp is the generation time of the dynamic data the cycle
+	// is based on, set for CycleStart and CycleEnd
+	Timestamp time.Time
+	// Previous is the object as it was last sent to the subscriber,
+	// set for ObjectModify and ObjectRemove updates
+	Previous interface{}
+	// Changes lists paths of the fields changed by an ObjectModify update,
+	// i.e. "Controllers.Tower", "Controllers.ATIS.AtisCode" or "Frequency".
+	// A controller facility path alone means the controller went online,
+	// offline or has been replaced
+	Changes []string
 }
 
-// UpdateFilter is a callback for filtering out particular objects
+// UpdateFilter is a callback for filtering out particular objects.
+// Filters scope the subscription state: objects not passing them
+// are neither sent nor stored
 type UpdateFilter func(interface{}) bool
 
 // Subscription is a subscriber descriptor
 // Use Updates() to get updates channel
 // Use Stop() to unsubscribe
 type Subscription struct {
-	subID          uint64
-	state          *State
-	updates        chan Update
-	controlledOnly bool
-	filters        []UpdateFilter
+	subID           uint64
+	lock            sync.RWMutex
+	state           *State
+	updates         chan Update
+	done            <-chan struct{}
+	quit            chan struct{}
+	quitOnce        sync.Once
+	closed          bool
+	controlledOnly  bool
+	filters         []UpdateFilter
+	opts            SubscriptionOptions
+	dropCount       uint64
+	resyncPending   int32
+	snapshotPending int32
+	coalesce        *coalesceQueue
+	missedObjects   map[string]missedObject
+	markers         bool
+	pendingMarkers  []Update
 }
 
-func (s *Subscription) processStatic(data *static.Data) {
-	// in case channel is already closed
-	if s.updates == nil {
-		return
-	}
-
-	// process countries
-	for _, vsCountry := range data.Countries {
-		country := Country{
-			Country: vsCountry,
-		}
-
-		if existing, found := s.state.Countries[country.Prefix]; found {
-			if !country.equals(&existing) {
-				if s.sendUpdate(Update{ObjectModify, country}) {
-					s.state.Countries[country.Prefix] = country
-				}
-			} else {
-				if s.sendUpdate(Update{ObjectAdd, country}) {
-					s.state.Countries[country.Prefix] = country
-				}
-			}
+// accepts returns true if the object passes the subscription filters
+func (s *Subscription) accepts(obj interface{}) bool {
+	if s.controlledOnly {
+		if airport, ok := obj.(Airport); ok && airport.IsEmpty() {
+			return false
 		}
 	}
-
-	for _, country := range s.state.Countries {
-		if data.FindCountryByPrefix(country.Prefix) == nil {
-			if s.sendUpdate(Update{ObjectRemove, country}) {
-				delete(s.state.Countries, country.Prefix)
-			}
+	for _, filter := range s.filters {
+		if !filter(obj) {
+			return false
 		}
 	}
+	return true
+}
 
-	// process airports
-	for _, vsAirport := range data.Airports {
-		airport := Airport{
-			Airport: vsAirport,
-		}
+// apply maps a canonical update to the subscription's view and sends it.
+// Filters scope the state: an object is added once it passes the filters
+// and removed once it stops passing them, objects not passing the filters
+// are neither sent nor stored. A dropped update isn't stored either,
+// the object is caught up with on the next cycle as well as the object
+// of an update evicted by DropOldest
+func (s *Subscription) apply(update Update) {
+	if s.isMissed(update) {
+		return
+	}
 
-		if existing, found := s.state.Airports[airport.ICAO]; found {
-			airport.Controllers.ATIS = existing.Controllers.ATIS
-			airport.Controllers.Delivery = existing.Controllers.Delivery
-			airport.Controllers.Ground = existing.Controllers.Ground
-			airport.Controllers.Tower = existing.Controllers.Tower
-			if !airport.equals(&existing) {
-				if airport.IsEmpty() && s.controlledOnly {
-					// This code should never run but just in case.
-					if s.sendUpdate(Update{ObjectRemove, airport}) {
-						delete(s.state.Airports, airport.ICAO)
-					}
-				} else {
-					if s.sendUpdate(Update{ObjectModify, airport}) {
-						s.state.Airports[airport.ICAO] = airport
-					}
-				}
-			}
-		} else {
-			if !airport.IsEmpty() || !s.controlledOnly {
-				if s.sendUpdate(Update{ObjectAdd, airport}) {
-					s.state.Airports[airport.ICAO] = airport
-				}
-			}
-		}
+	prev := s.state.lookup(update.Object)
+	visible := update.Type != ObjectRemove && s.accepts(update.Object)
+
+	switch {
+	case visible && prev == nil:
+		update.Type = ObjectAdd
+		update.Previous = nil
+		update.Changes = nil
+	case visible:
+		// the subscription's previous version may differ from the canonical
+		// one, i.e. after a drop, so changes are computed against it
+		update.Type = ObjectModify
+		update.Previous = prev
+		update.Changes = changedFields(prev, update.Object)
+	case prev != nil:
+		update.Type = ObjectRemove
+		update.Object = prev
+		update.Previous = prev
+		update.Changes = nil
+	default:
+		// invisible to the subscriber before and after
+		return
 	}
 
-	for _, airport := range s.state.Airports {
-		if data.FindAirportByICAO(airport.ICAO) == nil {
-			if s.sendUpdate(Update{ObjectRemove, airport}) {
-				delete(s.state.Airports, airport.ICAO)
-			}
-		}
+	if !s.sendUpdate(update) {
+		return
+	}
+	if update.Type == ObjectRemove {
+		s.state.remove(update.Object)
+	} else {
+		s.state.store(update.Object)
 	}
 }
 
-func (s *Subscription) processDynamic(dynamicData *dynamic.Data, staticData *static.Data) {
+// applyCycle applies a canonical diff of a fetch cycle to the subscription
+// catching up with the objects the consumer has missed updates of first
+func (s *Subscription) applyCycle(c *cycle, state *State) {
 	// in case channel is already closed
-	if s.updates == nil {
+	if s.closed {
 		return
 	}
 
-	// process controllers
-	for _, vsController := range dynamicData.Controllers {
-		if vsController.Facility >= 2 && vsController.Facility <= 5 {
-			controller := AirportController{
-				Controller: vsController,
-			}
-
-			tokens := strings.Split(controller.Callsign, "_")
-			prefix := tokens[0]
-			vsAirport := staticData.FindAirport(prefix)
-			if vsAirport == nil {
-				postfix := tokens[len(tokens)-1]
-				if postfix != "OBS" && postfix != "SUP" {
-					log.Debugf("can't find airport named %s, the controller is %v", prefix, controller)
-				}
-				continue
-			}
-
-			if airport, found := s.state.Airports[vsAirport.ICAO]; found {
-				var existing *AirportController
-				airportModified := false
-
-				switch controller.Facility {
-				case 2:
-					existing = airport.Controllers.Delivery
-					if !existing.equals(&controller) {
-						airport.Controllers.Delivery = &controller
-						airportModified = true
-					}
-				case 3:
-					existing = airport.Controllers.Ground
-					if !existing.equals(&controller) {
-						airport.Controllers.Ground = &controller
-						airportModified = true
-					}
-				case 4:
-					existing = airport.Controllers.Tower
-					if !existing.equals(&controller) {
-						airport.Controllers.Tower = &controller
-						airportModified = true
-					}
-				case 5:
-					existing = airport.Controllers.Approach
-					if !existing.equals(&controller) {
-						airport.Controllers.Approach = &controller
-						airportModified = true
-					}
-				}
-				if airportModified && s.sendUpdate(Update{ObjectModify, airport}) {
-					s.state.Airports[airport.ICAO] = airport
-				}
-			} else {
-				airport = Airport{
-					Airport: *vsAirport,
-				}
-				switch controller.Facility {
-				case 2:
-					airport.Controllers.Delivery = &controller
-				case 3:
-					airport.Controllers.Ground = &controller
-				case 4:
-					airport.Controllers.Tower = &controller
-				case 5:
-					airport.Controllers.Approach = &controller
-				}
-				if s.sendUpdate(Update{ObjectModify, airport}) {
-					s.state.Airports[airport.ICAO] = airport
-				}
-			}
-		} else if vsController.Facility == 6 {
-			// CTR
-			tokens := strings.Split(vsController.Callsign, "_")
-			prefix := tokens[0]
-			firs := make([]*FIR, 0)
-
-			fir := staticData.FindFIR(prefix)
-
-			if fir != nil {
-				firs = append(firs, &FIR{FIR: *fir})
-			} else {
-				uir := staticData.FindUIR(prefix)
-				if uir != nil {
-					for _, firID := range uir.FIRIDs {
-						fir = staticData.FindFIR(firID)
-						if fir != nil {
-							firs = append(firs, &FIR{FIR: *fir})
-						} else {
-							log.Debugf("can't find FIR %s provided by UIR %s", firID, uir.ID)
-						}
-					}
-				} else {
-					// silently catch special cases
-					postfix := tokens[len(tokens)-1]
-					if postfix == "OBS" || postfix == "SUP" {
-						continue
-					}
-
-					supervisorFound := false
-					for _, line := range vsController.TextAtis {
-						lowered := strings.ToLower(line)
-						if strings.Contains(lowered, "supervisor") {
-							supervisorFound = true
-							break
-						}
-					}
-
-					if supervisorFound {
-						continue
-					}
-
-					// no special cases found, log an error
-					log.Debugf("can't find FIR named %s, the controller is %v", prefix, vsController)
-					continue
-				}
-			}
-
-			if len(firs) == 0 {
-				log.Debugf("no FIRs or UIRs found by prefix %s for controller %v", prefix, vsController)
-				continue
-			}
-
-			radar := Radar{
-				Controller: vsController,
-				FIRs:       firs,
-			}
-
-			controlName := "Centre"
-			countryPrefix := fir.ID[:2]
-			country := staticData.FindCountryByPrefix(countryPrefix)
-			if country != nil && country.ControlCustomName != "" {
-				controlName = country.ControlCustomName
-			}
-			radar.HumanReadableName = fmt.Sprintf("%s %s", fir.Name, controlName)
-
-			if existing, found := s.state.Radars[radar.Callsign]; found {
-				if !existing.equals(&radar) {
-					if s.sendUpdate(Update{ObjectModify, radar}) {
-						s.state.Radars[radar.Callsign] = radar
-					}
-				}
-			} else {
-				if s.sendUpdate(Update{ObjectAdd, radar}) {
-					s.state.Radars[radar.Callsign] = radar
-				}
-			}
-		}
-	}
-
-	// process ATIS stations
-	for _, vsATIS := range dynamicData.ATIS {
-		atis := AirportController{
-			Controller: vsATIS,
-		}
-
-		tokens := strings.Split(atis.Callsign, "_")
-		prefix := tokens[0]
-		vsAirport := staticData.FindAirport(prefix)
-		if vsAirport == nil {
-			postfix := tokens[len(tokens)-1]
-			if postfix != "OBS" && postfix != "SUP" {
-				log.Debugf("can't find airport named %s, the controller is %v", prefix, atis)
-			}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.sendMarker(CycleStart, c)
+	caughtUp := s.catchUp(c, state)
+	for _, update := range c.updates {
+		if _, found := caughtUp[update.Key()]; found {
 			continue
 		}
-		if airport, found := s.state.Airports[vsAirport.ICAO]; found {
-			existing := airport.Controllers.ATIS
-			if !existing.equals(&atis) {
-				airport.Controllers.ATIS = &atis
-				if s.sendUpdate(Update{ObjectModify, airport}) {
-					s.state.Airports[vsAirport.ICAO] = airport
-				}
-			}
-		} else {
-			airport = Airport{
-				Airport: *vsAirport,
-			}
-			airport.Controllers.ATIS = &atis
-			if s.sendUpdate(Update{ObjectModify, airport}) {
-				s.state.Airports[airport.ICAO] = airport
-			}
-		}
+		s.apply(update)
 	}
+	s.sendMarker(CycleEnd, c)
+	s.state.setHead(c)
+}
 
-	// Removing controllers
-	for key, airport := range s.state.Airports {
-		// a readonly copy to keep changed values
-		current := s.state.Airports[key]
+// replay maps a historical canonical update to the subscription's view.
+// Unlike apply it relies on the previous object version carried by
+// the update rather than on the subscription state
+func (s *Subscription) replay(update Update) {
+	if s.isMissed(update) {
+		return
+	}
 
-		var ctrl *AirportController
-		ctrl = airport.Controllers.ATIS
-		if ctrl != nil {
-			if dct := dynamicData.FindController(ctrl.Callsign); dct == nil {
-				airport.Controllers.ATIS = nil
-			}
-		}
-		ctrl = airport.Controllers.Delivery
-		if ctrl != nil {
-			if dct := dynamicData.FindController(ctrl.Callsign); dct == nil {
-				airport.Controllers.Delivery = nil
-			}
-		}
-		ctrl = airport.Controllers.Ground
-		if ctrl != nil {
-			if dct := dynamicData.FindController(ctrl.Callsign); dct == nil {
-				airport.Controllers.Ground = nil
-			}
-		}
-		ctrl = airport.Controllers.Tower
-		if ctrl != nil {
-			if dct := dynamicData.FindController(ctrl.Callsign); dct == nil {
-				airport.Controllers.Tower = nil
-			}
-		}
-		ctrl = airport.Controllers.Approach
-		if ctrl != nil {
-			if dct := dynamicData.FindController(ctrl.Callsign); dct == nil {
-				airport.Controllers.Approach = nil
-			}
-		}
+	before := update.Previous != nil && s.accepts(update.Previous)
+	now := update.Type != ObjectRemove && s.accepts(update.Object)
+
+	switch {
+	case before && now:
+		// a canonical modify, nothing to change
+	case now:
+		update.Type = ObjectAdd
+		update.Previous = nil
+		update.Changes = nil
+	case before:
+		update.Type = ObjectRemove
+		update.Object = update.Previous
+		update.Changes = nil
+	default:
+		return
+	}
+	s.sendUpdate(update)
+}
 
-		if !current.equals(&airport) {
-			if airport.IsEmpty() && s.controlledOnly {
-				if s.sendUpdate(Update{ObjectRemove, current}) {
-					delete(s.state.Airports, key)
-				}
-			} else {
-				if s.sendUpdate(Update{ObjectModify, airport}) {
-					s.state.Airports[key] = airport
-				}
-			}
-		}
+// sendMarker sends a cycle boundary marker if the subscription asked for them.
+// Markers are never dropped: a marker which doesn't fit is kept pending
+// and the updates following it are dropped until it's sent. A cycle
+// which hasn't started for the consumer is not ended either
+func (s *Subscription) sendMarker(ut UpdateType, c *cycle) {
+	if !s.markers {
+		return
 	}
+	n := len(s.pendingMarkers)
+	if ut == CycleEnd && n > 0 && s.pendingMarkers[n-1].Type == CycleStart {
+		s.pendingMarkers = s.pendingMarkers[:n-1]
+		return
+	}
+	s.pendingMarkers = append(s.pendingMarkers, Update{Type: ut, Epoch: c.epoch, Revision: c.revision, Timestamp: c.timestamp})
+	s.flushMarkers()
+}
 
-	for callsign, radar := range s.state.Radars {
-		if ctrl := dynamicData.FindController(callsign); ctrl == nil {
-			if s.sendUpdate(Update{ObjectRemove, radar}) {
-				delete(s.state.Radars, callsign)
-			}
+// flushMarkers sends pending cycle markers.
+// Returns false if some of them are still pending
+func (s *Subscription) flushMarkers() bool {
+	for len(s.pendingMarkers) > 0 {
+		if !s.putMarker(s.pendingMarkers[0]) {
+			return false
 		}
+		s.pendingMarkers = s.pendingMarkers[1:]
 	}
+	return true
 }
 
 func (s *Subscription) sendUpdate(update Update) bool {
 	log.Debugf("sending %s %s", update.Type.String(), update.Object)
-	// apply filters before sending anything
-	for _, filter := range s.filters {
-		// if a filter returns false, do not send anything,
-		// but pretend we just did
-		if !filter(update.Object) {
-			return true
-		}
-	}
+	return s.deliver(update)
+}
 
-	// nonblocking send, drops the message if the channel buffer is full
-	select {
-	case s.updates <- update:
-		return true
-	default:
-		return false
+// cancel stops delivering updates right away, unlike close it's safe
+// to call from any goroutine so that a blocked delivery is released
+// before the loop gets to the unsubscribe request
+func (s *Subscription) cancel() {
+	s.quitOnce.Do(func() {
+		close(s.quit)
+	})
+}
+
+// close closes the updates channel, must be called with the provider lock held
+func (s *Subscription) close() {
+	if !s.closed {
+		s.closed = true
+		if s.coalesce != nil {
+			// the pump closes the channel on exit
+			close(s.coalesce.quit)
+		} else {
+			close(s.updates)
+		}
 	}
 }
 
@@ -399,7 +267,10 @@ func (s *Subscription) Updates() <-chan Update {
 	return s.updates
 }
 
-// GetState returns the current state
+// GetState returns a snapshot of the current state,
+// it's safe to use concurrently with updates
 func (s *Subscription) GetState() *State {
-	return s.state
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.state.Copy()
 }
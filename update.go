@@ -0,0 +1,119 @@
+package vatspy
+
+// ObjectKind is a kind of state object carried by an Update
+type ObjectKind int
+
+// ObjectKind enum definition
+const (
+	// KindNone is used by updates not carrying an object, i.e. StateReset
+	KindNone ObjectKind = iota
+	KindAirport
+	KindRadar
+	KindCountry
+	KindPilot
+)
+
+var objectKindNames = map[ObjectKind]string{
+	KindNone:    "none",
+	KindAirport: "airport",
+	KindRadar:   "radar",
+	KindCountry: "country",
+	KindPilot:   "pilot",
+}
+
+func (k ObjectKind) String() string {
+	return objectKindNames[k]
+}
+
+func kindOf(obj interface{}) ObjectKind {
+	switch obj.(type) {
+	case Airport:
+		return KindAirport
+	case Radar:
+		return KindRadar
+	case Country:
+		return KindCountry
+	case Pilot:
+		return KindPilot
+	}
+	return KindNone
+}
+
+func newUpdate(ut UpdateType, obj interface{}) Update {
+	return Update{Type: ut, Kind: kindOf(obj), Object: obj}
+}
+
+// Airport returns the updated airport if the update is about an airport
+func (u Update) Airport() (Airport, bool) {
+	a, ok := u.Object.(Airport)
+	return a, ok
+}
+
+// Radar returns the updated radar if the update is about a radar
+func (u Update) Radar() (Radar, bool) {
+	r, ok := u.Object.(Radar)
+	return r, ok
+}
+
+// Country returns the updated country if the update is about a country
+func (u Update) Country() (Country, bool) {
+	c, ok := u.Object.(Country)
+	return c, ok
+}
+
+// Pilot returns the updated pilot if the update is about a pilot
+func (u Update) Pilot() (Pilot, bool) {
+	p, ok := u.Object.(Pilot)
+	return p, ok
+}
+
+// PreviousAirport returns the previous version of the updated airport
+func (u Update) PreviousAirport() (Airport, bool) {
+	a, ok := u.Previous.(Airport)
+	return a, ok
+}
+
+// PreviousRadar returns the previous version of the updated radar
+func (u Update) PreviousRadar() (Radar, bool) {
+	r, ok := u.Previous.(Radar)
+	return r, ok
+}
+
+// PreviousCountry returns the previous version of the updated country
+func (u Update) PreviousCountry() (Country, bool) {
+	c, ok := u.Previous.(Country)
+	return c, ok
+}
+
+// PreviousPilot returns the previous version of the updated pilot
+func (u Update) PreviousPilot() (Pilot, bool) {
+	p, ok := u.Previous.(Pilot)
+	return p, ok
+}
+
+// Changed returns true if the field with the given path has been changed
+func (u Update) Changed(path string) bool {
+	for _, change := range u.Changes {
+		if change == path {
+			return true
+		}
+	}
+	return false
+}
+
+// Key returns the identity of the updated object which is unique
+// across all kinds, i.e. "airport:EGLL" or "pilot:BAW1".
+// Updates not carrying an object return an empty string
+func (u Update) Key() string {
+	switch o := u.Object.(type) {
+	case Airport:
+		return "airport:" + o.ICAO
+	case Radar:
+		return "radar:" + o.Callsign
+	case Country:
+		return "country:" + o.Prefix
+	case Pilot:
+		return "pilot:" + o.Callsign
+	}
+	return ""
+}
@@ -1,171 +1,445 @@
 package vatspy
 
 import (
+	"context"
 	"fmt"
+	"math/rand"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/viert/go-vatspy/dynamic"
+	"github.com/viert/go-vatspy/fetch"
 	"github.com/viert/go-vatspy/static"
 )
 
 // Provider is a vatspy data provider supporting automatic updates
 type Provider struct {
 	lock          sync.RWMutex
-	stop          chan *Subscription
-	cleanup       bool
-	staticData    *static.Data
-	dynamicData   *dynamic.Data
+	wake          chan struct{}
+	unsubscribe   chan *Subscription
+	done          chan struct{}
+	stopped       bool
+	staticData    atomic.Value // *static.Data
+	dynamicData   atomic.Value // *dynamic.Data
 	subscriptions map[uint64]*Subscription
 	autoinc       uint64
+	stateLock     sync.RWMutex
+	state         *State
+	history       *history
+	opts          Options
+	client        *fetch.Client
+	ctx           context.Context
+	cancel        context.CancelFunc
+
+	feedUpdatedAt  time.Time
+	feedAdvancedAt time.Time
+	feedStale      bool
+	feedReload     time.Duration
+	rnd            *rand.Rand
+	health         Health
 }
 
 // ReadyCallback is a callback function called when static data is initially loaded
 type ReadyCallback func()
 
-// New creates a new Provider
+// StaleFeedCallback is a callback function called when the dynamic feed
+// stops advancing (stale is true) and when it starts advancing again.
+// lastUpdate is the generation time of the most recent data received
+type StaleFeedCallback func(stale bool, lastUpdate time.Time)
+
+// New creates a new Provider fetching data from the default public sources
 func New(staticUpdatePeriod time.Duration, dynamicUpdatePeriod time.Duration, staticReady ReadyCallback) (*Provider, error) {
+	return NewWithOptions(Options{
+		StaticUpdatePeriod:  staticUpdatePeriod,
+		DynamicUpdatePeriod: dynamicUpdatePeriod,
+		StaticReady:         staticReady,
+	})
+}
+
+// NewWithOptions creates a new Provider configured with the given options.
+// Empty fields are set to their defaults.
+func NewWithOptions(opts Options) (*Provider, error) {
+	return Run(context.Background(), opts)
+}
+
+// Run creates a new Provider configured with the given options
+// and starts its update loop. The loop runs until the context is cancelled
+// or Stop is called, use Done or Wait to find out when it has fully exited
+func Run(ctx context.Context, opts Options) (*Provider, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	p := new(Provider)
-	p.stop = make(chan *Subscription, 1024)
+	p.opts = opts.withDefaults()
+	p.client = &fetch.Client{HTTPClient: p.opts.HTTPClient, Retry: p.opts.Retry}
+	p.ctx, p.cancel = context.WithCancel(ctx)
+	p.feedAdvancedAt = time.Now()
+	p.rnd = rand.New(rand.NewSource(time.Now().UnixNano()))
+	p.wake = make(chan struct{}, 1)
+	p.unsubscribe = make(chan *Subscription, 1024)
+	p.done = make(chan struct{})
 	p.subscriptions = make(map[uint64]*Subscription)
-	go p.loop(staticUpdatePeriod, dynamicUpdatePeriod, staticReady)
+	p.state = newStateData()
+	p.state.Epoch = newEpoch()
+	p.history = newHistory(p.opts.HistorySize)
+	go p.loop()
 	return p, nil
 }
 
 // Subscribe generates a new update channel
 func (p *Provider) Subscribe(chanSize int, controlledOnly bool, filters ...UpdateFilter) *Subscription {
+	return p.SubscribeWithOptions(SubscriptionOptions{
+		ChanSize:       chanSize,
+		ControlledOnly: controlledOnly,
+		Filters:        filters,
+	})
+}
+
+// SubscribeWithOptions generates a new update channel configured with the given options
+func (p *Provider) SubscribeWithOptions(opts SubscriptionOptions) *Subscription {
 	id := atomic.AddUint64(&p.autoinc, 1)
+	markers := opts.CycleMarkers && (opts.Delivery == DropNewest || opts.Delivery == Block)
+	chanSize := opts.ChanSize
+	if markers {
+		// reserved for CycleEnd
+		chanSize++
+	}
 	sub := &Subscription{
 		subID:          id,
 		state:          newStateData(),
 		updates:        make(chan Update, chanSize),
-		controlledOnly: controlledOnly,
-		filters:        filters,
+		done:           p.ctx.Done(),
+		quit:           make(chan struct{}),
+		controlledOnly: opts.ControlledOnly,
+		filters:        opts.Filters,
+		opts:           opts,
+		missedObjects:  make(map[string]missedObject),
+		markers:        markers,
+	}
+	if opts.Delivery == Coalesce {
+		sub.coalesce = newCoalesceQueue()
+		go sub.pump()
 	}
+
 	p.lock.Lock()
 	defer p.lock.Unlock()
+	if p.stopped {
+		// the provider is not running, nothing is ever going to be sent
+		sub.close()
+		return sub
+	}
+	// ask the loop to send the subscription a snapshot of the current state
+	sub.snapshotPending = 1
 	p.subscriptions[id] = sub
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
 	return sub
 }
 
 // Unsubscribe cancels the given subscription
 func (p *Provider) Unsubscribe(sub *Subscription) {
-	p.stop <- sub
+	sub.cancel()
+	select {
+	case p.unsubscribe <- sub:
+	case <-p.done:
+		// all the subscriptions are closed on exit
+	}
 }
 
 func (p *Provider) fetchDynamic() error {
 	var err error
-	if p.staticData == nil {
+	staticData := p.GetStaticData()
+	if staticData == nil {
 		return fmt.Errorf("static data is not available yet")
 	}
 
-	dynamicData, err := dynamic.Fetch(dynamic.VatSimJSON3URL)
+	dynamicData, err := p.opts.DynamicSource.LoadDynamic(p.ctx, p.client)
 	if err != nil {
 		return err
 	}
-	p.dynamicData = dynamicData
 
-	// safely copy subscriptions
-	subs := make([]*Subscription, 0)
+	p.feedReload = time.Duration(dynamicData.General.Reload) * time.Minute
+
+	updatedAt, err := dynamicData.General.UpdatedAt()
+	if err != nil {
+		log.Debugf("can't parse feed update timestamp %q: %v", dynamicData.General.UpdateTimestamp, err)
+	} else {
+		p.lock.Lock()
+		advanced := updatedAt.After(p.feedUpdatedAt)
+		if advanced {
+			p.feedUpdatedAt = updatedAt
+			p.feedAdvancedAt = time.Now()
+		}
+		p.lock.Unlock()
+
+		if !advanced {
+			// the feed hasn't been regenerated since the last fetch,
+			// only pending resyncs need to be served
+			p.servePending()
+			return nil
+		}
+	}
+	p.dynamicData.Store(dynamicData)
+
+	p.stateLock.Lock()
+	diff := newStateDiff(p.state)
+	diff.processDynamic(dynamicData, staticData)
+	if !updatedAt.IsZero() {
+		p.state.UpdatedAt = updatedAt
+	}
+	c := diff.cycle()
+	p.history.add(c)
+	p.stateLock.Unlock()
+
+	p.fanOut(c)
+	return nil
+}
+
+func (p *Provider) listSubscriptions() []*Subscription {
 	p.lock.RLock()
+	defer p.lock.RUnlock()
+	subs := make([]*Subscription, 0, len(p.subscriptions))
 	for _, sub := range p.subscriptions {
 		subs = append(subs, sub)
 	}
-	p.lock.RUnlock()
+	return subs
+}
+
+// fanOut applies the canonical diff of a fetch cycle to every subscription.
+// Subscriptions waiting for a snapshot or a resync get the whole state instead,
+// c is nil if there's no new cycle and only those should be served.
+// The canonical state is only modified by the loop, so no locking is needed here
+func (p *Provider) fanOut(c *cycle) {
+	for _, sub := range p.listSubscriptions() {
+		if sub.needsSnapshot() {
+			p.serveSnapshot(sub)
+		} else if sub.needsResync() {
+			sub.resync(p.state)
+		} else if c != nil {
+			sub.applyCycle(c, p.state)
+		}
+	}
+}
 
-	for _, sub := range subs {
-		sub.processDynamic(p.dynamicData, p.staticData)
+// serveSnapshot sends a new subscription the current state or the diffs
+// it has missed if it asks to resume from a revision still in history
+func (p *Provider) serveSnapshot(sub *Subscription) {
+	if sub.opts.ResumeFrom == 0 {
+		sub.snapshot(p.state)
+		return
 	}
 
-	return nil
+	if sub.opts.ResumeEpoch != p.state.Epoch {
+		log.Debugf("can't resume subscription %d from epoch %q, current is %q",
+			sub.subID, sub.opts.ResumeEpoch, p.state.Epoch)
+		sub.resync(p.state)
+		return
+	}
+
+	cycles, ok := p.history.since(sub.opts.ResumeFrom, p.state.Revision)
+	if !ok {
+		log.Debugf("can't resume subscription %d from revision %d, current is %d",
+			sub.subID, sub.opts.ResumeFrom, p.state.Revision)
+		sub.resync(p.state)
+		return
+	}
+	sub.resume(cycles, p.state)
+}
+
+// servePending sends pending snapshots and resyncs
+func (p *Provider) servePending() {
+	p.fanOut(nil)
+}
+
+func (p *Provider) checkStale() {
+	p.lock.Lock()
+	stale := time.Since(p.feedAdvancedAt) > p.opts.StaleFeedThreshold
+	changed := stale != p.feedStale
+	p.feedStale = stale
+	lastUpdate := p.feedUpdatedAt
+	p.lock.Unlock()
+
+	if changed {
+		if stale {
+			log.Warningf("dynamic feed is stale, last update at %s", lastUpdate)
+		}
+		if p.opts.StaleFeed != nil {
+			p.opts.StaleFeed(stale, lastUpdate)
+		}
+	}
 }
 
 func (p *Provider) fetchStatic() error {
-	data, err := static.Fetch(static.VATSpyDataPublicURL, static.FIRBoundariesPublicURL)
+	data, err := p.opts.StaticSource.LoadStatic(p.ctx, p.client)
+	if err == static.ErrNotModified {
+		return nil
+	}
 	if err != nil {
 		return err
 	}
 
-	// safely copy subscriptions
-	subs := make([]*Subscription, 0)
-	p.lock.RLock()
-	for _, sub := range p.subscriptions {
-		subs = append(subs, sub)
+	if current := p.GetStaticData(); current != nil && current.Checksum == data.Checksum {
+		// nothing has changed, no need to diff
+		return nil
 	}
-	p.lock.RUnlock()
 
-	for _, sub := range subs {
-		sub.processStatic(data)
-	}
-	p.staticData = data
+	p.stateLock.Lock()
+	diff := newStateDiff(p.state)
+	diff.processStatic(data)
+	c := diff.cycle()
+	p.history.add(c)
+	p.stateLock.Unlock()
+
+	p.staticData.Store(data)
+	p.fanOut(c)
 	return nil
 }
 
-func (p *Provider) loop(staticUpdatePeriod time.Duration, dynamicUpdatePeriod time.Duration, staticReady ReadyCallback) {
+// nextDynamicPeriod returns the delay before the next dynamic poll
+func (p *Provider) nextDynamicPeriod() time.Duration {
+	period := p.opts.DynamicUpdatePeriod
+	if p.opts.AdaptivePolling && p.feedReload > 0 {
+		period = p.feedReload
+		if period < p.opts.MinDynamicUpdatePeriod {
+			period = p.opts.MinDynamicUpdatePeriod
+		}
+		if period > p.opts.MaxDynamicUpdatePeriod {
+			period = p.opts.MaxDynamicUpdatePeriod
+		}
+	}
+	if p.opts.PollJitter > 0 {
+		period += time.Duration(p.rnd.Int63n(int64(p.opts.PollJitter)))
+	}
+	return period
+}
+
+func (p *Provider) loop() {
+	defer p.shutdown()
+
+	staticReady := p.opts.StaticReady
 	staticReadyCalled := false
 
-	st := time.NewTicker(staticUpdatePeriod)
-	dt := time.NewTicker(dynamicUpdatePeriod)
+	st := time.NewTicker(p.opts.StaticUpdatePeriod)
+	defer st.Stop()
 
 	err := p.fetchStatic()
+	p.reportFetch(SourceStatic, err)
 	if err == nil && staticReady != nil {
 		staticReady()
 		staticReadyCalled = true
 	}
-	p.fetchDynamic()
+	p.reportFetch(SourceDynamic, p.fetchDynamic())
+	p.checkStale()
+
+	dt := time.NewTimer(p.nextDynamicPeriod())
+	defer dt.Stop()
 
 	for {
 		select {
-		case sub := <-p.stop:
-
-			if sub == nil {
-				p.stop = nil
-				p.lock.Lock()
-				for _, sub := range p.subscriptions {
-					close(sub.updates)
-					sub.updates = nil
-				}
-				st.Stop()
-				dt.Stop()
-				p.lock.Unlock()
-				return
-			}
+		case <-p.ctx.Done():
+			return
+
+		case <-p.wake:
+			p.servePending()
 
-			if sub.updates != nil {
-				p.lock.Lock()
-				close(sub.updates)
-				sub.updates = nil
+		case sub := <-p.unsubscribe:
+			p.lock.Lock()
+			if _, found := p.subscriptions[sub.subID]; found {
+				sub.close()
 				delete(p.subscriptions, sub.subID)
-				p.lock.Unlock()
 			}
+			p.lock.Unlock()
 
 		case <-st.C:
 			err := p.fetchStatic()
+			p.reportFetch(SourceStatic, err)
 			if !staticReadyCalled && staticReady != nil && err == nil {
 				staticReady()
 				staticReadyCalled = true
 			}
 		case <-dt.C:
-			p.fetchDynamic()
+			p.reportFetch(SourceDynamic, p.fetchDynamic())
+			p.checkStale()
+			dt.Reset(p.nextDynamicPeriod())
 		}
 	}
 }
 
-// Stop stops the provider's update loop
-func (p *Provider) Stop() {
-	if !p.cleanup {
-		p.cleanup = true
-		p.stop <- nil
+// shutdown closes all the subscriptions when the loop exits
+func (p *Provider) shutdown() {
+	p.cancel()
+	p.lock.Lock()
+	p.stopped = true
+	for id, sub := range p.subscriptions {
+		sub.close()
+		delete(p.subscriptions, id)
 	}
+	p.lock.Unlock()
+	close(p.done)
+}
+
+// Stop stops the provider's update loop aborting in-flight fetches.
+// It doesn't wait for the loop to exit, use Wait for that
+func (p *Provider) Stop() {
+	p.cancel()
+}
+
+// Done returns a channel which is closed when the update loop
+// has fully exited and all the subscriptions are closed
+func (p *Provider) Done() <-chan struct{} {
+	return p.done
+}
+
+// Wait blocks until the update loop has fully exited
+func (p *Provider) Wait() {
+	<-p.done
 }
 
-// GetStaticData returns current static data object
+// GetStaticData returns current static data object.
+// The object is replaced, not modified, on updates so it's safe
+// to use concurrently but must be treated as read-only
 func (p *Provider) GetStaticData() *static.Data {
-	return p.staticData
+	data, _ := p.staticData.Load().(*static.Data)
+	return data
 }
 
-// GetDynamicData returns current dynamic data object
+// GetDynamicData returns current dynamic data object.
+// The object is replaced, not modified, on updates so it's safe
+// to use concurrently but must be treated as read-only
 func (p *Provider) GetDynamicData() *dynamic.Data {
-	return p.dynamicData
+	data, _ := p.dynamicData.Load().(*dynamic.Data)
+	return data
+}
+
+// GetState returns a snapshot of the canonical state, unfiltered
+// and including airports without controllers
+func (p *Provider) GetState() *State {
+	p.stateLock.RLock()
+	defer p.stateLock.RUnlock()
+	return p.state.Copy()
+}
+
+// Epoch returns the random identifier of the provider instance,
+// revisions are only meaningful within the same epoch
+func (p *Provider) Epoch() string {
+	return p.state.Epoch
+}
+
+// Revision returns the revision of the last fetch cycle
+func (p *Provider) Revision() uint64 {
+	p.stateLock.RLock()
+	defer p.stateLock.RUnlock()
+	return p.state.Revision
+}
+
+// FeedStale returns true if the dynamic feed's update timestamp
+// hasn't advanced for longer than the configured threshold
+func (p *Provider) FeedStale() bool {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.feedStale
 }